@@ -3,213 +3,479 @@ package whatsappclient
 import (
 	"context"
 	"fmt"
-	"os"
-	"os/signal"
-	"syscall"
+	"io"
+	"time"
 
 	messages "github.com/hacxk/easy-meow/Message"
+	utils "github.com/hacxk/easy-meow/Utils"
 
 	_ "github.com/mattn/go-sqlite3"
-	"github.com/mdp/qrterminal/v3"
 	"go.mau.fi/whatsmeow"
+	"go.mau.fi/whatsmeow/store"
 	"go.mau.fi/whatsmeow/store/sqlstore"
+	"go.mau.fi/whatsmeow/types"
 	"go.mau.fi/whatsmeow/types/events"
 	waLog "go.mau.fi/whatsmeow/util/log"
 )
 
+// DefaultSendTimeout is how long a send, including any media upload, may take when the caller's context has no deadline
+const DefaultSendTimeout = 2 * time.Minute
+
 type ExtendedClient struct {
 	*whatsmeow.Client
+
+	// DefaultTimeout bounds every send whose context has no deadline of its own. Zero disables it.
+	DefaultTimeout time.Duration
+
+	// StickerMetadata is the pack name and publisher embedded in stickers sent with SendSticker
+	StickerMetadata utils.StickerMetadata
+
+	// VideoOptions controls how SendVideo normalizes and compresses videos
+	VideoOptions utils.VideoOptions
+
+	// LinkPreviews fetches previews for links in texts sent with Send, Reply and SendTextTo. Nil sends links without a preview.
+	LinkPreviews *messages.PreviewFetcher
+
+	// EphemeralTimers caches the disappearing messages timer of each chat. Sends through the client
+	// disappear with the chat's timer; nil sends messages that stay.
+	EphemeralTimers *messages.EphemeralTimers
+
+	// MaxDownloadSize is the largest media DownloadMedia and SaveMedia accept. Zero means messages.DefaultMaxDownloadSize.
+	MaxDownloadSize int64
+
+	// inflight counts sends and downloads in progress so Run can wait for them on shutdown
+	inflight tracker
+	// conn tracks the connection state for WaitConnected
+	conn *connectionSupervisor
+	// groupLookups remembers failed group info lookups for disappearing timers
+	groupLookups groupLookupFailures
 }
 
-func (ec *ExtendedClient) Send(evt *events.Message, message string) (*whatsmeow.SendResponse, error) {
-	return messages.SendTextMessage(ec.Client, evt, message)
+// withTimeout applies DefaultTimeout to ctx unless the caller already set a deadline.
+// The operation counts as in flight until the returned cancel function is called.
+func (ec *ExtendedClient) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	ec.inflight.add()
+	cancel := context.CancelFunc(func() {})
+	if _, ok := ctx.Deadline(); !ok && ec.DefaultTimeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, ec.DefaultTimeout)
+	}
+	return ctx, func() {
+		cancel()
+		ec.inflight.done()
+	}
+}
+
+func (ec *ExtendedClient) Send(ctx context.Context, msg *messages.IncomingMessage, message string) (*whatsmeow.SendResponse, error) {
+	return ec.SendTextTo(ctx, msg.Chat(), message)
 }
 
-func (ec *ExtendedClient) React(evt *events.Message, emoji string) (*whatsmeow.SendResponse, error) {
-	return messages.ReactionMessage(ec.Client, evt, emoji)
+func (ec *ExtendedClient) React(ctx context.Context, msg *messages.IncomingMessage, emoji string) (*whatsmeow.SendResponse, error) {
+	ctx, cancel := ec.withTimeout(ctx)
+	defer cancel()
+	return messages.ReactionMessage(ctx, ec.Client, msg.Event, emoji)
 }
 
-func (ec *ExtendedClient) Edit(evt *events.Message, sendMessageID string, newMessage string) (*whatsmeow.SendResponse, error) {
-	return messages.EditMessage(ec.Client, evt, sendMessageID, newMessage)
+func (ec *ExtendedClient) Edit(ctx context.Context, msg *messages.IncomingMessage, sendMessageID string, newMessage string) (*whatsmeow.SendResponse, error) {
+	ctx, cancel := ec.withTimeout(ctx)
+	defer cancel()
+	return messages.EditMessage(ctx, ec.Client, msg.Event, sendMessageID, newMessage)
 }
 
-func (ec *ExtendedClient) Reply(evt *events.Message, message string) (*whatsmeow.SendResponse, error) {
-	return messages.ReplyToMessage(ec.Client, evt, message)
+func (ec *ExtendedClient) Reply(ctx context.Context, msg *messages.IncomingMessage, message string) (*whatsmeow.SendResponse, error) {
+	return ec.SendBuilt(ctx, msg.Chat(), ec.newText(message).ReplyTo(msg.Event))
+}
+
+// newText starts a text message with a link preview when LinkPreviews is set
+func (ec *ExtendedClient) newText(message string) *messages.MessageBuilder {
+	builder := messages.NewText(message)
+	if ec.LinkPreviews != nil {
+		builder.AutoPreview(ec.LinkPreviews)
+	}
+	return builder
 }
 
-func (ec *ExtendedClient) SendImage(evt *events.Message, path string, caption ...string) (*whatsmeow.SendResponse, error) {
+func (ec *ExtendedClient) SendImage(ctx context.Context, msg *messages.IncomingMessage, media utils.MediaSource, caption ...string) (*whatsmeow.SendResponse, error) {
 	var finalCaption string
 	if len(caption) > 0 {
 		finalCaption = caption[0]
 	}
-	return messages.SendImageMessage(ec.Client, evt, path, finalCaption)
+	return ec.SendImageTo(ctx, msg.Chat(), media, finalCaption)
 }
 
-func (ec *ExtendedClient) SendImageReply(evt *events.Message, path string, caption ...string) (*whatsmeow.SendResponse, error) {
+func (ec *ExtendedClient) SendImageReply(ctx context.Context, msg *messages.IncomingMessage, media utils.MediaSource, caption ...string) (*whatsmeow.SendResponse, error) {
 	var finalCaption string
 	if len(caption) > 0 {
 		finalCaption = caption[0]
 	}
-	return messages.SendImageMessageReply(ec.Client, evt, path, finalCaption)
+	return ec.SendBuilt(ctx, msg.Chat(), messages.NewImage(media).Caption(finalCaption).ReplyTo(msg.Event))
 }
 
-func (ec *ExtendedClient) SendVideo(evt *events.Message, path string, caption ...string) (*whatsmeow.SendResponse, error) {
+func (ec *ExtendedClient) SendVideo(ctx context.Context, msg *messages.IncomingMessage, media utils.MediaSource, caption ...string) (*whatsmeow.SendResponse, error) {
 	var finalCaption string
 	if len(caption) > 0 {
 		finalCaption = caption[0]
 	}
-	return messages.SendVideoMessage(ec.Client, evt, path, finalCaption)
+	return ec.SendVideoTo(ctx, msg.Chat(), media, finalCaption)
+}
+
+func (ec *ExtendedClient) SendVideoReply(ctx context.Context, msg *messages.IncomingMessage, media utils.MediaSource, caption ...string) (*whatsmeow.SendResponse, error) {
+	var finalCaption string
+	if len(caption) > 0 {
+		finalCaption = caption[0]
+	}
+	builder := messages.NewVideo(media).Caption(finalCaption).VideoOptions(ec.VideoOptions).ReplyTo(msg.Event)
+	return ec.SendBuilt(ctx, msg.Chat(), builder)
+}
+
+func (ec *ExtendedClient) SendAudio(ctx context.Context, msg *messages.IncomingMessage, media utils.MediaSource, ptt bool) (*whatsmeow.SendResponse, error) {
+	return ec.SendAudioTo(ctx, msg.Chat(), media, ptt)
+}
+
+func (ec *ExtendedClient) SendAudioReply(ctx context.Context, msg *messages.IncomingMessage, media utils.MediaSource, ptt bool) (*whatsmeow.SendResponse, error) {
+	return ec.SendBuilt(ctx, msg.Chat(), messages.NewAudioPTT(media, ptt).ReplyTo(msg.Event))
+}
+
+func (ec *ExtendedClient) SendDocument(ctx context.Context, msg *messages.IncomingMessage, media utils.MediaSource, filename string, caption ...string) (*whatsmeow.SendResponse, error) {
+	var finalCaption string
+	if len(caption) > 0 {
+		finalCaption = caption[0]
+	}
+	return ec.SendDocumentTo(ctx, msg.Chat(), media, filename, finalCaption)
+}
+
+func (ec *ExtendedClient) SendDocumentReply(ctx context.Context, msg *messages.IncomingMessage, media utils.MediaSource, filename string, caption ...string) (*whatsmeow.SendResponse, error) {
+	var finalCaption string
+	if len(caption) > 0 {
+		finalCaption = caption[0]
+	}
+	return ec.SendBuilt(ctx, msg.Chat(), messages.NewDocument(media).FileName(filename).Caption(finalCaption).ReplyTo(msg.Event))
+}
+
+func (ec *ExtendedClient) SendSticker(ctx context.Context, msg *messages.IncomingMessage, media utils.MediaSource) (*whatsmeow.SendResponse, error) {
+	return ec.SendStickerTo(ctx, msg.Chat(), media)
+}
+
+func (ec *ExtendedClient) SendStickerReply(ctx context.Context, msg *messages.IncomingMessage, media utils.MediaSource) (*whatsmeow.SendResponse, error) {
+	return ec.SendBuilt(ctx, msg.Chat(), messages.NewSticker(media).StickerPack(ec.StickerMetadata).ReplyTo(msg.Event))
+}
+
+func (ec *ExtendedClient) SendGif(ctx context.Context, msg *messages.IncomingMessage, media utils.MediaSource, caption ...string) (*whatsmeow.SendResponse, error) {
+	var finalCaption string
+	if len(caption) > 0 {
+		finalCaption = caption[0]
+	}
+	return ec.SendGifTo(ctx, msg.Chat(), media, finalCaption)
+}
+
+func (ec *ExtendedClient) SendGifReply(ctx context.Context, msg *messages.IncomingMessage, media utils.MediaSource, caption ...string) (*whatsmeow.SendResponse, error) {
+	var finalCaption string
+	if len(caption) > 0 {
+		finalCaption = caption[0]
+	}
+	return ec.SendBuilt(ctx, msg.Chat(), messages.NewGif(media).Caption(finalCaption).ReplyTo(msg.Event))
+}
+
+func (ec *ExtendedClient) SendMention(ctx context.Context, msg *messages.IncomingMessage, message string, mentions []string) (*whatsmeow.SendResponse, error) {
+	return ec.SendMentionTo(ctx, msg.Chat(), message, mentions)
+}
+
+func (ec *ExtendedClient) SendPhone(ctx context.Context, msg *messages.IncomingMessage, phonenumber string, message string) (*whatsmeow.SendResponse, error) {
+	return ec.SendPhoneTo(ctx, msg.Chat(), phonenumber, message)
 }
 
-func (ec *ExtendedClient) SendVideoReply(evt *events.Message, path string, caption ...string) (*whatsmeow.SendResponse, error) {
+// SendContact shares one or more contact cards in the chat of msg
+func (ec *ExtendedClient) SendContact(ctx context.Context, msg *messages.IncomingMessage, contacts ...*messages.Contact) (*whatsmeow.SendResponse, error) {
+	return ec.SendContactTo(ctx, msg.Chat(), contacts...)
+}
+
+// SendContactReply shares contact cards as a reply to msg
+func (ec *ExtendedClient) SendContactReply(ctx context.Context, msg *messages.IncomingMessage, contacts ...*messages.Contact) (*whatsmeow.SendResponse, error) {
+	return ec.SendBuilt(ctx, msg.Chat(), messages.NewContacts(contacts...).ReplyTo(msg.Event))
+}
+
+// SendLocation sends a location pin in the chat of msg
+func (ec *ExtendedClient) SendLocation(ctx context.Context, msg *messages.IncomingMessage, location messages.Location) (*whatsmeow.SendResponse, error) {
+	return ec.SendLocationTo(ctx, msg.Chat(), location)
+}
+
+// SendLocationReply sends a location pin as a reply to msg
+func (ec *ExtendedClient) SendLocationReply(ctx context.Context, msg *messages.IncomingMessage, location messages.Location) (*whatsmeow.SendResponse, error) {
+	return ec.SendBuilt(ctx, msg.Chat(), messages.NewLocation(location).ReplyTo(msg.Event))
+}
+
+// SendViewOnceImage sends an image to the chat of msg that can only be opened once
+func (ec *ExtendedClient) SendViewOnceImage(ctx context.Context, msg *messages.IncomingMessage, media utils.MediaSource, caption ...string) (*whatsmeow.SendResponse, error) {
+	return ec.SendViewOnceImageTo(ctx, msg.Chat(), media, caption...)
+}
+
+// SendViewOnceVideo sends a video to the chat of msg that can only be opened once
+func (ec *ExtendedClient) SendViewOnceVideo(ctx context.Context, msg *messages.IncomingMessage, media utils.MediaSource, caption ...string) (*whatsmeow.SendResponse, error) {
+	return ec.SendViewOnceVideoTo(ctx, msg.Chat(), media, caption...)
+}
+
+// SendViewOnceVoice sends a voice note to the chat of msg that can only be played once
+func (ec *ExtendedClient) SendViewOnceVoice(ctx context.Context, msg *messages.IncomingMessage, media utils.MediaSource) (*whatsmeow.SendResponse, error) {
+	return ec.SendViewOnceVoiceTo(ctx, msg.Chat(), media)
+}
+
+func (ec *ExtendedClient) CreatePoll(ctx context.Context, msg *messages.IncomingMessage, question string, option []string, onlyonce bool) (*whatsmeow.SendResponse, error) {
+	return ec.CreatePollTo(ctx, msg.Chat(), question, option, onlyonce)
+}
+
+func (ec *ExtendedClient) Delete(ctx context.Context, msg *messages.IncomingMessage, messageID string) (*whatsmeow.SendResponse, error) {
+	ctx, cancel := ec.withTimeout(ctx)
+	defer cancel()
+	return messages.DeleteMessage(ctx, ec.Client, msg.Event, messageID)
+}
+
+// The *To methods send to any chat or user instead of the chat of an incoming event.
+// Use messages.ParseRecipient to turn a phone number into a JID.
+
+func (ec *ExtendedClient) SendTextTo(ctx context.Context, to types.JID, message string) (*whatsmeow.SendResponse, error) {
+	return ec.SendBuilt(ctx, to, ec.newText(message))
+}
+
+func (ec *ExtendedClient) SendImageTo(ctx context.Context, to types.JID, media utils.MediaSource, caption ...string) (*whatsmeow.SendResponse, error) {
 	var finalCaption string
 	if len(caption) > 0 {
 		finalCaption = caption[0]
 	}
-	return messages.SendVideoMessageReply(ec.Client, evt, path, finalCaption)
+	return ec.SendBuilt(ctx, to, messages.NewImage(media).Caption(finalCaption))
 }
 
-func (ec *ExtendedClient) SendAudio(evt *events.Message, path string, ptt bool) (*whatsmeow.SendResponse, error) {
-	return messages.SendAudioMessage(ec.Client, evt, path, ptt)
+func (ec *ExtendedClient) SendVideoTo(ctx context.Context, to types.JID, media utils.MediaSource, caption ...string) (*whatsmeow.SendResponse, error) {
+	var finalCaption string
+	if len(caption) > 0 {
+		finalCaption = caption[0]
+	}
+	builder := messages.NewVideo(media).Caption(finalCaption).VideoOptions(ec.VideoOptions)
+	return ec.SendBuilt(ctx, to, builder)
 }
 
-func (ec *ExtendedClient) SendAudioReply(evt *events.Message, path string, ptt bool) (*whatsmeow.SendResponse, error) {
-	return messages.SendAudioMessageReply(ec.Client, evt, path, ptt)
+func (ec *ExtendedClient) SendAudioTo(ctx context.Context, to types.JID, media utils.MediaSource, ptt bool) (*whatsmeow.SendResponse, error) {
+	return ec.SendBuilt(ctx, to, messages.NewAudioPTT(media, ptt))
 }
 
-func (ec *ExtendedClient) SendDocument(evt *events.Message, path string, filename string, caption ...string) (*whatsmeow.SendResponse, error) {
+func (ec *ExtendedClient) SendDocumentTo(ctx context.Context, to types.JID, media utils.MediaSource, filename string, caption ...string) (*whatsmeow.SendResponse, error) {
 	var finalCaption string
 	if len(caption) > 0 {
 		finalCaption = caption[0]
 	}
-	return messages.SendDocumentMessage(ec.Client, evt, path, filename, finalCaption)
+	return ec.SendBuilt(ctx, to, messages.NewDocument(media).FileName(filename).Caption(finalCaption))
 }
 
-func (ec *ExtendedClient) SendDocumentReply(evt *events.Message, path string, filename string, caption ...string) (*whatsmeow.SendResponse, error) {
+func (ec *ExtendedClient) SendStickerTo(ctx context.Context, to types.JID, media utils.MediaSource) (*whatsmeow.SendResponse, error) {
+	return ec.SendBuilt(ctx, to, messages.NewSticker(media).StickerPack(ec.StickerMetadata))
+}
+
+func (ec *ExtendedClient) SendGifTo(ctx context.Context, to types.JID, media utils.MediaSource, caption ...string) (*whatsmeow.SendResponse, error) {
 	var finalCaption string
 	if len(caption) > 0 {
 		finalCaption = caption[0]
 	}
-	return messages.SendDocumentMessageReply(ec.Client, evt, path, filename, finalCaption)
+	return ec.SendBuilt(ctx, to, messages.NewGif(media).Caption(finalCaption))
+}
+
+func (ec *ExtendedClient) SendMentionTo(ctx context.Context, to types.JID, message string, mentions []string) (*whatsmeow.SendResponse, error) {
+	return ec.SendBuilt(ctx, to, ec.newText(message).Mention(mentions...))
 }
 
-func (ec *ExtendedClient) SendSticker(evt *events.Message, path string) (*whatsmeow.SendResponse, error) {
-	return messages.SendStickerMessage(ec.Client, evt, path)
+func (ec *ExtendedClient) SendPhoneTo(ctx context.Context, to types.JID, phonenumber string, message string) (*whatsmeow.SendResponse, error) {
+	return ec.SendBuilt(ctx, to, messages.NewPhoneNumberText(phonenumber, message))
 }
 
-func (ec *ExtendedClient) SendStickerReply(evt *events.Message, path string) (*whatsmeow.SendResponse, error) {
-	return messages.SendStickerMessageReply(ec.Client, evt, path)
+// SendContactTo shares one or more contact cards with any chat
+func (ec *ExtendedClient) SendContactTo(ctx context.Context, to types.JID, contacts ...*messages.Contact) (*whatsmeow.SendResponse, error) {
+	return ec.SendBuilt(ctx, to, messages.NewContacts(contacts...))
 }
 
-func (ec *ExtendedClient) SendGif(evt *events.Message, path string, caption ...string) (*whatsmeow.SendResponse, error) {
+// SendLocationTo sends a location pin to any chat
+func (ec *ExtendedClient) SendLocationTo(ctx context.Context, to types.JID, location messages.Location) (*whatsmeow.SendResponse, error) {
+	return ec.SendBuilt(ctx, to, messages.NewLocation(location))
+}
+
+// ShareLiveLocation shares a live location with any chat and sends each position from updates until the
+// channel is closed or ctx ends. It blocks and isn't subject to DefaultTimeout, so run it in a goroutine.
+func (ec *ExtendedClient) ShareLiveLocation(ctx context.Context, to types.JID, initial messages.Location, updates <-chan messages.Location) error {
+	return messages.ShareLiveLocation(ctx, initial, updates, func(builder *messages.MessageBuilder) error {
+		_, err := ec.SendBuilt(ctx, to, builder)
+		return err
+	})
+}
+
+// SendViewOnceImageTo sends an image to any chat that can only be opened once
+func (ec *ExtendedClient) SendViewOnceImageTo(ctx context.Context, to types.JID, media utils.MediaSource, caption ...string) (*whatsmeow.SendResponse, error) {
 	var finalCaption string
 	if len(caption) > 0 {
 		finalCaption = caption[0]
 	}
-	return messages.SendGifMessage(ec.Client, evt, path, finalCaption)
+	return ec.SendBuilt(ctx, to, messages.NewImage(media).Caption(finalCaption).ViewOnce())
 }
 
-func (ec *ExtendedClient) SendGifReply(evt *events.Message, path string, caption ...string) (*whatsmeow.SendResponse, error) {
+// SendViewOnceVideoTo sends a video to any chat that can only be opened once
+func (ec *ExtendedClient) SendViewOnceVideoTo(ctx context.Context, to types.JID, media utils.MediaSource, caption ...string) (*whatsmeow.SendResponse, error) {
 	var finalCaption string
 	if len(caption) > 0 {
 		finalCaption = caption[0]
 	}
-	return messages.SendGifMessageReply(ec.Client, evt, path, finalCaption)
+	builder := messages.NewVideo(media).Caption(finalCaption).VideoOptions(ec.VideoOptions).ViewOnce()
+	return ec.SendBuilt(ctx, to, builder)
 }
 
-func (ec *ExtendedClient) SendMention(evt *events.Message, message string, mentions []string) (*whatsmeow.SendResponse, error) {
-	return messages.SendMentionMessage(ec.Client, evt, message, mentions)
+// SendViewOnceVoiceTo sends a voice note to any chat that can only be played once
+func (ec *ExtendedClient) SendViewOnceVoiceTo(ctx context.Context, to types.JID, media utils.MediaSource) (*whatsmeow.SendResponse, error) {
+	return ec.SendBuilt(ctx, to, messages.NewAudio(media).PTT().ViewOnce())
 }
 
-func (ec *ExtendedClient) SendPhone(evt *events.Message, phonenumber string, message string) (*whatsmeow.SendResponse, error) {
-	return messages.SendPhoneNumberMessage(ec.Client, evt, phonenumber, message)
+func (ec *ExtendedClient) CreatePollTo(ctx context.Context, to types.JID, question string, option []string, onlyonce bool) (*whatsmeow.SendResponse, error) {
+	return ec.SendBuilt(ctx, to, messages.NewPoll(question, option, onlyonce))
+}
+
+// SendBuilt sends a message composed with the messages builder, e.g.
+// ec.SendBuilt(ctx, to, messages.NewImage(src).Caption("hi").ReplyTo(msg.Event).ViewOnce()).
+// What the builder changed to make the media sendable is logged.
+func (ec *ExtendedClient) SendBuilt(ctx context.Context, to types.JID, builder *messages.MessageBuilder) (*whatsmeow.SendResponse, error) {
+	ctx, cancel := ec.withTimeout(ctx)
+	defer cancel()
+	builder.InheritExpiration(ec.chatExpiration(ctx, to))
+	resp, err := messages.SendBuilt(ctx, ec.Client, to, builder)
+	for _, change := range builder.Changes() {
+		ec.Log.Infof("Prepared media before sending: %s", change)
+	}
+	return resp, err
 }
 
-func (ec *ExtendedClient) CreatePoll(evt *events.Message, question string, option []string, onlyonce bool) (*whatsmeow.SendResponse, error) {
-	return messages.SendPolls(ec.Client, evt, question, option, onlyonce)
+// DownloadMedia writes the image, video, audio, document or sticker of msg to w, checking its size
+func (ec *ExtendedClient) DownloadMedia(ctx context.Context, msg *messages.IncomingMessage, w io.Writer) (int64, error) {
+	ctx, cancel := ec.withTimeout(ctx)
+	defer cancel()
+	return msg.Download(ctx, ec.Client, w, messages.DownloadOptions{MaxSize: ec.MaxDownloadSize})
 }
 
-func (ec *ExtendedClient) Delete(evt *events.Message, messageID string) (*whatsmeow.SendResponse, error) {
-	return messages.DeleteMessage(ec.Client, evt, messageID)
+// SaveMedia downloads the media of msg into dir with a file name and extension derived from the message, and returns the path
+func (ec *ExtendedClient) SaveMedia(ctx context.Context, msg *messages.IncomingMessage, dir string) (string, error) {
+	ctx, cancel := ec.withTimeout(ctx)
+	defer cancel()
+	return msg.SaveTo(ctx, ec.Client, dir, messages.DownloadOptions{MaxSize: ec.MaxDownloadSize})
 }
 
 type WhatsAppClient struct {
-	client *ExtendedClient
-	dbPath string
+	client     *ExtendedClient
+	dbPath     string
+	container  *sqlstore.Container
+	pipeline   messagePipeline
+	dispatcher *dispatcher
+	conn       *connectionSupervisor
+
+	loginOptions LoginOptions
+	relink       relinkTracker
+
+	// handlerCtx is passed to message handlers and is only cancelled when Run gives up waiting for them
+	handlerCtx      context.Context
+	cancelHandlers  context.CancelFunc
+	shutdownTimeout time.Duration
 }
 
-func NewWhatsAppClient(dbPath string) (*WhatsAppClient, error) {
-	dbLog := waLog.Stdout("Database", "INFO", true)
-	container, err := sqlstore.New("sqlite3", fmt.Sprintf("file:%s?_foreign_keys=on", dbPath), dbLog)
+// NewWhatsAppClient creates a client for the session stored in the sqlite file at dbPath.
+// Options can switch to another database, pick a device and configure logging.
+func NewWhatsAppClient(dbPath string, opts ...Option) (*WhatsAppClient, error) {
+	cfg := newClientConfig(dbPath, opts)
+	container, err := cfg.openContainer()
 	if err != nil {
-		return nil, fmt.Errorf("failed to initialize SQL store: %w", err)
+		return nil, err
 	}
-
-	deviceStore, err := container.GetFirstDevice()
+	deviceStore, err := cfg.openDevice(container)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get device from store: %w", err)
+		return nil, err
 	}
+	cfg.applyDeviceName()
+	return newWhatsAppClient(dbPath, container, deviceStore, cfg.newLogger("WhatsApp")), nil
+}
 
-	clientLog := waLog.Stdout("WhatsApp", "INFO", true)
+// newWhatsAppClient wraps a device from the store in a client with the default settings
+func newWhatsAppClient(dbPath string, container *sqlstore.Container, deviceStore *store.Device, clientLog waLog.Logger) *WhatsAppClient {
 	client := whatsmeow.NewClient(deviceStore, clientLog)
 
-	extendedClient := &ExtendedClient{Client: client}
+	conn := newConnectionSupervisor(client, clientLog.Sub("Connection"))
+	extendedClient := &ExtendedClient{
+		Client:          client,
+		DefaultTimeout:  DefaultSendTimeout,
+		VideoOptions:    utils.DefaultVideoOptions,
+		EphemeralTimers: messages.NewEphemeralTimers(),
+		conn:            conn,
+	}
 
-	return &WhatsAppClient{
-		client: extendedClient,
-		dbPath: dbPath,
-	}, nil
+	handlerCtx, cancelHandlers := context.WithCancel(context.Background())
+	wac := &WhatsAppClient{
+		client:          extendedClient,
+		dbPath:          dbPath,
+		container:       container,
+		dispatcher:      newDispatcher(clientLog.Sub("Dispatch"), DefaultDispatchOptions),
+		conn:            conn,
+		handlerCtx:      handlerCtx,
+		cancelHandlers:  cancelHandlers,
+		shutdownTimeout: DefaultShutdownTimeout,
+	}
+	client.AddEventHandler(extendedClient.observeEphemeral)
+	client.AddEventHandler(func(evt interface{}) {
+		if loggedOut, ok := evt.(*events.LoggedOut); ok {
+			go wac.handleLoggedOut(loggedOut)
+		}
+	})
+	return wac
 }
 
+// Connect connects with the stored session, or links a new device as set up with SetLoginOptions
 func (wac *WhatsAppClient) Connect(ctx context.Context) error {
+	wac.conn.starting()
+	var err error
 	if wac.client.Store.ID == nil {
-		qrChan, _ := wac.client.GetQRChannel(ctx)
-		err := wac.client.Connect()
-		if err != nil {
-			return fmt.Errorf("failed to connect: %w", err)
-		}
-		for evt := range qrChan {
-			if evt.Event == "code" {
-				qrterminal.GenerateHalfBlock(evt.Code, qrterminal.L, os.Stdout)
-				fmt.Println("Scan the QR code above to log in")
-			} else {
-				fmt.Println("Login event:", evt.Event)
-			}
-		}
-	} else {
-		err := wac.client.Connect()
-		if err != nil {
-			return fmt.Errorf("failed to connect: %w", err)
-		}
+		err = wac.login(ctx)
+	} else if err = wac.client.Connect(); err != nil {
+		err = fmt.Errorf("failed to connect: %w", err)
 	}
-
-	wac.setupSignalHandler()
-	return nil
+	if err != nil {
+		wac.conn.setState(StateChange{State: StateDisconnected, Err: err})
+	}
+	return err
 }
 
 func (wac *WhatsAppClient) GetClient() *ExtendedClient {
 	return wac.client
 }
 
-func (wac *WhatsAppClient) setupSignalHandler() {
-	c := make(chan os.Signal, 1)
-	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
-	go func() {
-		<-c
-		fmt.Println("\nDisconnecting...")
-		wac.client.Disconnect()
-		os.Exit(0)
-	}()
-}
-
+// Disconnect closes the connection and cancels any pending reconnect
 func (wac *WhatsAppClient) Disconnect() {
+	wac.conn.stopped()
 	wac.client.Disconnect()
 }
 
+// SetDefaultTimeout changes how long sends may take when their context has no deadline. Zero disables the limit.
+func (wac *WhatsAppClient) SetDefaultTimeout(timeout time.Duration) {
+	wac.client.DefaultTimeout = timeout
+}
+
+// SetStickerMetadata sets the pack name, publisher and emojis shown on stickers the bot sends
+func (wac *WhatsAppClient) SetStickerMetadata(metadata utils.StickerMetadata) {
+	wac.client.StickerMetadata = metadata
+}
+
+// SetVideoOptions changes how videos are normalized and compressed before upload
+func (wac *WhatsAppClient) SetVideoOptions(opts utils.VideoOptions) {
+	wac.client.VideoOptions = opts
+}
+
+// SetLinkPreviews turns on link previews for texts sent with Send, Reply and SendTextTo.
+// Pass messages.DefaultPreviewFetcher or your own fetcher to enable them and nil to disable them.
+func (wac *WhatsAppClient) SetLinkPreviews(fetcher *messages.PreviewFetcher) {
+	wac.client.LinkPreviews = fetcher
+}
+
 func (wac *WhatsAppClient) IsConnected() bool {
 	return wac.client.IsConnected()
 }
 
-func (wac *WhatsAppClient) AddEventHandler(handler func(interface{})) {
-	wac.client.AddEventHandler(handler)
+// AddEventHandler registers a handler for every whatsmeow event. The typed On* methods are usually easier.
+func (wac *WhatsAppClient) AddEventHandler(handler func(interface{})) *Subscription {
+	return &Subscription{client: wac.client.Client, id: wac.client.AddEventHandler(handler)}
 }
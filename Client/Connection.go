@@ -0,0 +1,386 @@
+package whatsappclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"sync"
+	"time"
+
+	"go.mau.fi/whatsmeow"
+	"go.mau.fi/whatsmeow/types/events"
+	waLog "go.mau.fi/whatsmeow/util/log"
+)
+
+// ConnectionState is where the connection to WhatsApp currently stands
+type ConnectionState int
+
+const (
+	StateDisconnected ConnectionState = iota
+	StateConnecting
+	StateConnected
+	// StateLoggedOut means the device was unlinked and has to be paired again
+	StateLoggedOut
+	// StateBanned means the account was banned; reconnecting won't help
+	StateBanned
+	// StateTemporarilyBanned means the account is banned until StateChange.BanExpires has passed, when the client reconnects
+	StateTemporarilyBanned
+)
+
+func (s ConnectionState) String() string {
+	switch s {
+	case StateDisconnected:
+		return "disconnected"
+	case StateConnecting:
+		return "connecting"
+	case StateConnected:
+		return "connected"
+	case StateLoggedOut:
+		return "logged out"
+	case StateBanned:
+		return "banned"
+	case StateTemporarilyBanned:
+		return "temporarily banned"
+	default:
+		return fmt.Sprintf("ConnectionState(%d)", int(s))
+	}
+}
+
+// final reports whether the state can't recover by reconnecting. A temporary ban ends by itself.
+func (s ConnectionState) final() bool {
+	return s == StateLoggedOut || s == StateBanned
+}
+
+var (
+	// ErrReconnectGaveUp is the StateChange error after ReconnectOptions.MaxAttempts failed attempts
+	ErrReconnectGaveUp = errors.New("gave up reconnecting")
+	// ErrNotLoggedIn is returned by WaitConnected when the device is logged out or banned
+	ErrNotLoggedIn = errors.New("device is logged out or banned")
+)
+
+// StateChange describes a transition of the connection state
+type StateChange struct {
+	State    ConnectionState
+	Previous ConnectionState
+	// Err is why the connection was lost or a reconnect attempt failed, if known
+	Err error
+	// Attempt is the number of the reconnect attempt while reconnecting, otherwise zero
+	Attempt int
+	// BanExpires is how long a temporary ban lasts, when WhatsApp says so
+	BanExpires time.Duration
+}
+
+// ReconnectOptions controls how the client reconnects after losing the connection
+type ReconnectOptions struct {
+	// Disable turns off automatic reconnection
+	Disable bool
+	// InitialBackoff is the wait before the first attempt. Default 2 seconds.
+	InitialBackoff time.Duration
+	// MaxBackoff caps the wait between attempts. Default 5 minutes.
+	MaxBackoff time.Duration
+	// Multiplier grows the wait after each failed attempt. Default 2.
+	Multiplier float64
+	// Jitter randomizes each wait by up to this fraction, so many bots don't reconnect in lockstep. Default 0.2.
+	Jitter float64
+	// MaxAttempts stops reconnecting after this many failed attempts. Zero retries forever.
+	MaxAttempts int
+}
+
+// DefaultReconnectOptions retries forever, waiting 2s, 4s, 8s... up to 5 minutes between attempts
+var DefaultReconnectOptions = ReconnectOptions{
+	InitialBackoff: 2 * time.Second,
+	MaxBackoff:     5 * time.Minute,
+	Multiplier:     2,
+	Jitter:         0.2,
+}
+
+// backoff returns how long to wait before the given attempt, starting at 1
+func (opts ReconnectOptions) backoff(attempt int) time.Duration {
+	delay := float64(opts.InitialBackoff) * math.Pow(opts.Multiplier, float64(attempt-1))
+	if delay > float64(opts.MaxBackoff) {
+		delay = float64(opts.MaxBackoff)
+	}
+	if opts.Jitter > 0 {
+		delay *= 1 + opts.Jitter*(2*rand.Float64()-1)
+	}
+	return time.Duration(delay)
+}
+
+// connectionSupervisor tracks the connection state from whatsmeow events and reconnects with backoff.
+// It replaces whatsmeow's own auto-reconnect, which retries forever at a fixed pace.
+type connectionSupervisor struct {
+	client *whatsmeow.Client
+	log    waLog.Logger
+
+	mu           sync.Mutex
+	opts         ReconnectOptions
+	state        ConnectionState
+	changed      chan struct{}
+	callbacks    []func(StateChange)
+	attempts     int
+	reconnecting bool
+	stop         chan struct{}
+}
+
+func newConnectionSupervisor(client *whatsmeow.Client, log waLog.Logger) *connectionSupervisor {
+	s := &connectionSupervisor{
+		client:  client,
+		log:     log,
+		changed: make(chan struct{}),
+		stop:    make(chan struct{}),
+	}
+	s.configure(DefaultReconnectOptions)
+	client.EnableAutoReconnect = false
+	client.AddEventHandler(s.handleEvent)
+	return s
+}
+
+func (s *connectionSupervisor) configure(opts ReconnectOptions) {
+	if opts.InitialBackoff <= 0 {
+		opts.InitialBackoff = DefaultReconnectOptions.InitialBackoff
+	}
+	if opts.MaxBackoff <= 0 {
+		opts.MaxBackoff = DefaultReconnectOptions.MaxBackoff
+	}
+	if opts.Multiplier < 1 {
+		opts.Multiplier = DefaultReconnectOptions.Multiplier
+	}
+	s.mu.Lock()
+	s.opts = opts
+	s.mu.Unlock()
+}
+
+func (s *connectionSupervisor) current() ConnectionState {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.state
+}
+
+// setState records a transition and tells the callbacks about it
+func (s *connectionSupervisor) setState(change StateChange) {
+	s.mu.Lock()
+	change.Previous = s.state
+	if change.State == change.Previous && change.Err == nil {
+		s.mu.Unlock()
+		return
+	}
+	s.state = change.State
+	close(s.changed)
+	s.changed = make(chan struct{})
+	callbacks := append([](func(StateChange))(nil), s.callbacks...)
+	s.mu.Unlock()
+
+	if change.Err != nil {
+		s.log.Infof("Connection %s: %v", change.State, change.Err)
+	} else {
+		s.log.Infof("Connection %s", change.State)
+	}
+	for _, callback := range callbacks {
+		callback(change)
+	}
+}
+
+// backoff returns the wait before the given attempt with the configured reconnect options
+func (s *connectionSupervisor) backoff(attempt int) time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.opts.backoff(attempt)
+}
+
+// stopping returns a channel that is closed by the next manual disconnect
+func (s *connectionSupervisor) stopping() <-chan struct{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.stop
+}
+
+// starting is called before a manual connect, re-arming reconnection after a manual disconnect
+func (s *connectionSupervisor) starting() {
+	s.mu.Lock()
+	select {
+	case <-s.stop:
+		s.stop = make(chan struct{})
+	default:
+	}
+	s.mu.Unlock()
+	s.setState(StateChange{State: StateConnecting})
+}
+
+// stopped is called on a manual disconnect and cancels any pending reconnect
+func (s *connectionSupervisor) stopped() {
+	s.mu.Lock()
+	select {
+	case <-s.stop:
+	default:
+		close(s.stop)
+	}
+	// Logged out and banned say more than disconnected, so they stay
+	final := s.state.final() || s.state == StateTemporarilyBanned
+	s.mu.Unlock()
+	if !final {
+		s.setState(StateChange{State: StateDisconnected})
+	}
+}
+
+func (s *connectionSupervisor) handleEvent(evt interface{}) {
+	switch evt := evt.(type) {
+	case *events.Connected:
+		s.mu.Lock()
+		s.attempts = 0
+		s.mu.Unlock()
+		s.setState(StateChange{State: StateConnected})
+	case *events.Disconnected:
+		// whatsmeow only sends this for unexpected disconnects, which are worth retrying
+		s.setState(StateChange{State: StateDisconnected})
+		go s.reconnect()
+	case *events.StreamReplaced:
+		s.setState(StateChange{State: StateDisconnected, Err: errors.New("another client connected with the same session")})
+	case *events.ClientOutdated:
+		s.setState(StateChange{State: StateDisconnected, Err: errors.New("WhatsApp rejected the client version as outdated")})
+	case *events.ConnectFailure:
+		s.setState(StateChange{State: StateDisconnected, Err: fmt.Errorf("connect failure %s: %s", evt.Reason, evt.Message)})
+	case *events.LoggedOut:
+		state := StateLoggedOut
+		if evt.Reason == events.ConnectFailureUnknownLogout {
+			state = StateBanned
+		}
+		s.setState(StateChange{State: state, Err: fmt.Errorf("logged out: %s", evt.Reason)})
+	case *events.TemporaryBan:
+		s.setState(StateChange{State: StateTemporarilyBanned, Err: errors.New(evt.String()), BanExpires: evt.Expire})
+		go s.reconnectAfterBan(evt.Expire)
+	}
+}
+
+// reconnectAfterBan tries connecting once when a temporary ban has expired. If that fails for other
+// reasons the usual backoff takes over; if the ban was extended, WhatsApp says so and this starts again.
+func (s *connectionSupervisor) reconnectAfterBan(expires time.Duration) {
+	s.mu.Lock()
+	if s.opts.Disable {
+		s.mu.Unlock()
+		return
+	}
+	stop := s.stop
+	if expires <= 0 {
+		// WhatsApp didn't say how long the ban lasts
+		expires = s.opts.MaxBackoff
+	}
+	s.mu.Unlock()
+
+	s.log.Infof("Temporarily banned, reconnecting in %v", expires)
+	select {
+	case <-stop:
+		return
+	case <-time.After(expires):
+	}
+
+	s.setState(StateChange{State: StateConnecting})
+	err := s.client.Connect()
+	if err == nil || errors.Is(err, whatsmeow.ErrAlreadyConnected) {
+		return
+	}
+	s.setState(StateChange{State: StateDisconnected, Err: err})
+	s.reconnect()
+}
+
+// reconnect retries connecting with backoff until it succeeds, runs out of attempts or is stopped
+func (s *connectionSupervisor) reconnect() {
+	s.mu.Lock()
+	if s.reconnecting || s.opts.Disable {
+		s.mu.Unlock()
+		return
+	}
+	s.reconnecting = true
+	stop := s.stop
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		s.reconnecting = false
+		s.mu.Unlock()
+	}()
+
+	for {
+		s.mu.Lock()
+		s.attempts++
+		attempt, opts := s.attempts, s.opts
+		s.mu.Unlock()
+
+		if opts.MaxAttempts > 0 && attempt > opts.MaxAttempts {
+			s.setState(StateChange{State: StateDisconnected, Err: fmt.Errorf("%w after %d attempts", ErrReconnectGaveUp, opts.MaxAttempts)})
+			return
+		}
+
+		delay := opts.backoff(attempt)
+		s.log.Debugf("Reconnecting in %v (attempt %d)", delay, attempt)
+		select {
+		case <-stop:
+			return
+		case <-time.After(delay):
+		}
+
+		s.setState(StateChange{State: StateConnecting, Attempt: attempt})
+		err := s.client.Connect()
+		if err == nil || errors.Is(err, whatsmeow.ErrAlreadyConnected) {
+			// The Connected event resets the attempts; another Disconnected starts over from here
+			return
+		}
+		s.setState(StateChange{State: StateDisconnected, Err: err, Attempt: attempt})
+	}
+}
+
+// wait blocks until the connection is up, it can't come back without pairing, or ctx ends
+func (s *connectionSupervisor) wait(ctx context.Context) error {
+	for {
+		s.mu.Lock()
+		state, changed := s.state, s.changed
+		s.mu.Unlock()
+
+		switch {
+		case state == StateConnected:
+			return nil
+		case state.final():
+			return fmt.Errorf("%w: %s", ErrNotLoggedIn, state)
+		}
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// SetReconnectOptions changes how the client reconnects after losing the connection
+func (wac *WhatsAppClient) SetReconnectOptions(opts ReconnectOptions) {
+	wac.conn.configure(opts)
+}
+
+// OnStateChange calls callback on every connection state transition
+func (wac *WhatsAppClient) OnStateChange(callback func(change StateChange)) {
+	wac.conn.mu.Lock()
+	defer wac.conn.mu.Unlock()
+	wac.conn.callbacks = append(wac.conn.callbacks, callback)
+}
+
+// State returns the current connection state
+func (wac *WhatsAppClient) State() ConnectionState {
+	return wac.conn.current()
+}
+
+// WaitConnected blocks until the client is connected and logged in, so sends made afterwards can go through.
+// It fails right away when the device is logged out or permanently banned, and waits out a temporary ban.
+func (wac *WhatsAppClient) WaitConnected(ctx context.Context) error {
+	return wac.client.WaitConnected(ctx)
+}
+
+// WaitConnected blocks until the client is connected and logged in, so sends made afterwards can go through
+func (ec *ExtendedClient) WaitConnected(ctx context.Context) error {
+	if ec.conn == nil {
+		if ec.IsConnected() && ec.IsLoggedIn() {
+			return nil
+		}
+		return fmt.Errorf("client is not connected")
+	}
+	return ec.conn.wait(ctx)
+}
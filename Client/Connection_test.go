@@ -0,0 +1,88 @@
+package whatsappclient
+
+import (
+	"testing"
+	"time"
+)
+
+func TestReconnectBackoff(t *testing.T) {
+	opts := ReconnectOptions{InitialBackoff: 2 * time.Second, MaxBackoff: time.Minute, Multiplier: 2}
+	tests := []struct {
+		name    string
+		jitter  float64
+		attempt int
+		base    time.Duration
+	}{
+		{name: "first attempt", attempt: 1, base: 2 * time.Second},
+		{name: "second attempt", attempt: 2, base: 4 * time.Second},
+		{name: "fifth attempt", attempt: 5, base: 32 * time.Second},
+		{name: "capped", attempt: 6, base: time.Minute},
+		{name: "stays capped", attempt: 100, base: time.Minute},
+		{name: "jitter first attempt", jitter: 0.2, attempt: 1, base: 2 * time.Second},
+		{name: "jitter fifth attempt", jitter: 0.2, attempt: 5, base: 32 * time.Second},
+		{name: "jitter capped", jitter: 0.2, attempt: 100, base: time.Minute},
+		{name: "full jitter", jitter: 1, attempt: 3, base: 8 * time.Second},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := opts
+			opts.Jitter = tt.jitter
+			low := time.Duration(float64(tt.base) * (1 - tt.jitter))
+			high := time.Duration(float64(tt.base) * (1 + tt.jitter))
+
+			var min, max time.Duration
+			for i := 0; i < 1000; i++ {
+				delay := opts.backoff(tt.attempt)
+				if delay < low || delay > high {
+					t.Fatalf("backoff = %v, want between %v and %v", delay, low, high)
+				}
+				if i == 0 || delay < min {
+					min = delay
+				}
+				if delay > max {
+					max = delay
+				}
+			}
+			if tt.jitter == 0 && min != max {
+				t.Errorf("backoff varies between %v and %v without jitter", min, max)
+			}
+			// With 1000 samples the spread should cover a good part of the jitter range
+			if tt.jitter > 0 && max-min < (high-low)/2 {
+				t.Errorf("backoff only spread from %v to %v, want most of %v to %v", min, max, low, high)
+			}
+		})
+	}
+}
+
+func TestReconnectOptionsDefaults(t *testing.T) {
+	s := &connectionSupervisor{}
+	s.configure(ReconnectOptions{Jitter: 0.1, MaxAttempts: 3})
+	if s.opts.InitialBackoff != DefaultReconnectOptions.InitialBackoff ||
+		s.opts.MaxBackoff != DefaultReconnectOptions.MaxBackoff ||
+		s.opts.Multiplier != DefaultReconnectOptions.Multiplier {
+		t.Errorf("zero options weren't replaced by the defaults: %+v", s.opts)
+	}
+	if s.opts.Jitter != 0.1 || s.opts.MaxAttempts != 3 {
+		t.Errorf("set options were overwritten: %+v", s.opts)
+	}
+}
+
+func TestConnectionStateFinal(t *testing.T) {
+	tests := []struct {
+		state ConnectionState
+		final bool
+	}{
+		{StateDisconnected, false},
+		{StateConnecting, false},
+		{StateConnected, false},
+		{StateLoggedOut, true},
+		{StateBanned, true},
+		{StateTemporarilyBanned, false},
+	}
+	for _, tt := range tests {
+		if got := tt.state.final(); got != tt.final {
+			t.Errorf("%s: final = %v, want %v", tt.state, got, tt.final)
+		}
+	}
+}
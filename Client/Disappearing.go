@@ -0,0 +1,131 @@
+package whatsappclient
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	messages "github.com/hacxk/easy-meow/Message"
+	utils "github.com/hacxk/easy-meow/Utils"
+
+	"go.mau.fi/whatsmeow"
+	"go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/types/events"
+)
+
+// Disappearing message timers the official apps offer
+const (
+	DisappearingOff     = whatsmeow.DisappearingTimerOff
+	Disappearing24Hours = whatsmeow.DisappearingTimer24Hours
+	Disappearing7Days   = whatsmeow.DisappearingTimer7Days
+	Disappearing90Days  = whatsmeow.DisappearingTimer90Days
+)
+
+// observeEphemeral keeps the disappearing timers of chats up to date from incoming events
+func (ec *ExtendedClient) observeEphemeral(evt interface{}) {
+	if ec.EphemeralTimers == nil {
+		return
+	}
+	switch evt := evt.(type) {
+	case *events.Message:
+		ec.EphemeralTimers.Observe(messages.NewIncomingMessage(evt))
+	case *events.GroupInfo:
+		ec.EphemeralTimers.ObserveGroupChange(evt)
+	case *events.JoinedGroup:
+		ec.EphemeralTimers.ObserveGroup(&evt.GroupInfo)
+	}
+}
+
+// DisappearingTimer returns the disappearing messages timer of a chat as far as the client knows it.
+// Zero means off or not known yet.
+func (ec *ExtendedClient) DisappearingTimer(chat types.JID) time.Duration {
+	if ec.EphemeralTimers == nil {
+		return 0
+	}
+	return ec.EphemeralTimers.Get(chat)
+}
+
+// groupLookupRetry is how long chatExpiration sends without a timer after failing to look up a group
+const groupLookupRetry = 5 * time.Minute
+
+// groupLookupFailures remembers groups whose info couldn't be fetched, so every send doesn't wait on them
+type groupLookupFailures struct {
+	mu    sync.Mutex
+	until map[types.JID]time.Time
+}
+
+func (f *groupLookupFailures) recent(group types.JID) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	retry, ok := f.until[group]
+	if ok && time.Now().After(retry) {
+		delete(f.until, group)
+		return false
+	}
+	return ok
+}
+
+func (f *groupLookupFailures) add(group types.JID) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.until == nil {
+		f.until = make(map[types.JID]time.Time)
+	}
+	f.until[group] = time.Now().Add(groupLookupRetry)
+}
+
+// chatExpiration returns the timer sends to chat should use. A group the client hasn't seen a message
+// from yet is looked up once, so the first reply in it already disappears. A failed lookup isn't retried
+// for a while and the message is sent without a timer.
+func (ec *ExtendedClient) chatExpiration(ctx context.Context, chat types.JID) time.Duration {
+	if ec.EphemeralTimers == nil {
+		return 0
+	}
+	if timer, ok := ec.EphemeralTimers.Lookup(chat); ok || chat.Server != types.GroupServer {
+		return timer
+	}
+	if ec.groupLookups.recent(chat) {
+		return 0
+	}
+
+	// whatsmeow's GetGroupInfo has no context, so it is abandoned if ctx ends first
+	info, err := utils.AwaitContext(ctx, func() (*types.GroupInfo, error) {
+		return ec.GetGroupInfo(chat)
+	})
+	if err != nil {
+		ec.Log.Warnf("Failed to get disappearing timer of %s: %v", chat, err)
+		ec.groupLookups.add(chat)
+		return 0
+	}
+	ec.EphemeralTimers.ObserveGroup(info)
+	return ec.EphemeralTimers.Get(chat)
+}
+
+// SetDisappearingMessages turns on disappearing messages in a private chat or group.
+// Use one of the Disappearing* timers; the official apps ignore others and groups reject them.
+func (ec *ExtendedClient) SetDisappearingMessages(ctx context.Context, chat types.JID, timer time.Duration) error {
+	ctx, cancel := ec.withTimeout(ctx)
+	defer cancel()
+
+	// whatsmeow's SetDisappearingTimer has no context, so it is abandoned if ctx ends first
+	_, err := utils.AwaitContext(ctx, func() (struct{}, error) {
+		return struct{}{}, ec.SetDisappearingTimer(chat, timer)
+	})
+	if err != nil {
+		if ctx.Err() != nil {
+			return err
+		}
+		return fmt.Errorf("failed to set disappearing timer: %w", err)
+	}
+
+	if ec.EphemeralTimers != nil {
+		ec.EphemeralTimers.Set(chat, timer)
+	}
+	return nil
+}
+
+// DisableDisappearingMessages turns disappearing messages off in a private chat or group
+func (ec *ExtendedClient) DisableDisappearingMessages(ctx context.Context, chat types.JID) error {
+	return ec.SetDisappearingMessages(ctx, chat, DisappearingOff)
+}
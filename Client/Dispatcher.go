@@ -0,0 +1,203 @@
+package whatsappclient
+
+import (
+	"context"
+	"runtime/debug"
+	"sync"
+
+	"go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/types/events"
+	waLog "go.mau.fi/whatsmeow/util/log"
+)
+
+// OverflowPolicy decides what happens to an incoming message when the dispatch queue is full
+type OverflowPolicy int
+
+const (
+	// OverflowBlock makes whatsmeow wait until there is room, which slows down reading from the socket
+	OverflowBlock OverflowPolicy = iota
+	// OverflowDrop discards the new message and reports it to OnDrop
+	OverflowDrop
+)
+
+// DispatchOptions controls how incoming messages are spread over handler goroutines.
+// Messages of one chat are always handled in order; different chats run in parallel.
+type DispatchOptions struct {
+	// Workers is how many messages are handled at the same time across all chats
+	Workers int
+	// MaxQueued is how many messages may wait or run across all chats
+	MaxQueued int
+	// MaxQueuedPerChat keeps one busy chat from filling the whole queue. Zero means no separate limit.
+	MaxQueuedPerChat int
+	// Overflow is what happens when a limit is reached
+	Overflow OverflowPolicy
+	// OnDrop is called with messages discarded by OverflowDrop. By default they are logged.
+	OnDrop func(evt *events.Message)
+}
+
+// DefaultDispatchOptions handles 16 messages at once and blocks when 1024 are pending
+var DefaultDispatchOptions = DispatchOptions{Workers: 16, MaxQueued: 1024, MaxQueuedPerChat: 256}
+
+// dispatcher runs message handlers on a bounded number of goroutines while keeping per-chat order
+type dispatcher struct {
+	log waLog.Logger
+
+	mu     sync.Mutex
+	cond   *sync.Cond
+	opts   DispatchOptions
+	sem    chan struct{}
+	chats  map[types.JID]*chatQueue
+	queued int
+	closed bool
+
+	// active tracks queued and running messages so shutdown can wait for them
+	active tracker
+}
+
+// chatQueue holds the messages of one chat that haven't started yet. A chat has a queue
+// exactly while a goroutine is draining it.
+type chatQueue struct {
+	tasks []dispatchTask
+}
+
+type dispatchTask struct {
+	evt *events.Message
+	run func()
+}
+
+func newDispatcher(log waLog.Logger, opts DispatchOptions) *dispatcher {
+	d := &dispatcher{log: log, chats: make(map[types.JID]*chatQueue)}
+	d.cond = sync.NewCond(&d.mu)
+	d.configure(opts)
+	return d
+}
+
+// configure replaces the options. Handlers already running finish under the old worker limit.
+func (d *dispatcher) configure(opts DispatchOptions) {
+	if opts.Workers <= 0 {
+		opts.Workers = DefaultDispatchOptions.Workers
+	}
+	if opts.MaxQueued <= 0 {
+		opts.MaxQueued = DefaultDispatchOptions.MaxQueued
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.opts = opts
+	d.sem = make(chan struct{}, opts.Workers)
+	d.cond.Broadcast()
+}
+
+// pending returns how many messages are waiting or being handled
+func (d *dispatcher) pending() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.queued
+}
+
+// submit queues run for the chat of evt. It reports false when the message was dropped.
+func (d *dispatcher) submit(evt *events.Message, run func()) bool {
+	chat := evt.Info.Chat.ToNonAD()
+
+	d.mu.Lock()
+	for !d.closed && d.full(chat) {
+		if d.opts.Overflow == OverflowDrop {
+			onDrop := d.opts.OnDrop
+			d.mu.Unlock()
+			if onDrop != nil {
+				onDrop(evt)
+			} else {
+				d.log.Warnf("Dispatch queue is full, dropping message %s from %s in %s", evt.Info.ID, evt.Info.Sender, evt.Info.Chat)
+			}
+			return false
+		}
+		d.cond.Wait()
+	}
+	if d.closed {
+		d.mu.Unlock()
+		d.log.Debugf("Shutting down, not handling message %s in %s", evt.Info.ID, evt.Info.Chat)
+		return false
+	}
+
+	queue, ok := d.chats[chat]
+	if !ok {
+		queue = &chatQueue{}
+		d.chats[chat] = queue
+		go d.drain(chat, queue)
+	}
+	queue.tasks = append(queue.tasks, dispatchTask{evt: evt, run: run})
+	d.queued++
+	d.active.add()
+	d.mu.Unlock()
+	return true
+}
+
+func (d *dispatcher) full(chat types.JID) bool {
+	if d.queued >= d.opts.MaxQueued {
+		return true
+	}
+	queue, ok := d.chats[chat]
+	return ok && d.opts.MaxQueuedPerChat > 0 && len(queue.tasks) >= d.opts.MaxQueuedPerChat
+}
+
+// drain handles the messages of one chat in order until its queue is empty
+func (d *dispatcher) drain(chat types.JID, queue *chatQueue) {
+	for {
+		d.mu.Lock()
+		if len(queue.tasks) == 0 {
+			delete(d.chats, chat)
+			d.mu.Unlock()
+			return
+		}
+		task := queue.tasks[0]
+		queue.tasks[0] = dispatchTask{}
+		queue.tasks = queue.tasks[1:]
+		sem := d.sem
+		d.cond.Broadcast()
+		d.mu.Unlock()
+
+		// The worker slot is taken per message so a busy chat can't starve the others
+		sem <- struct{}{}
+		d.run(task)
+		<-sem
+
+		d.mu.Lock()
+		d.queued--
+		d.cond.Broadcast()
+		d.mu.Unlock()
+		d.active.done()
+	}
+}
+
+// close stops accepting messages. Messages already queued are still handled.
+func (d *dispatcher) close() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.closed = true
+	d.cond.Broadcast()
+}
+
+// wait blocks until every queued message has been handled or ctx ends
+func (d *dispatcher) wait(ctx context.Context) error {
+	return d.active.wait(ctx)
+}
+
+// run calls the handler, logging a panic along with the message that caused it instead of crashing
+func (d *dispatcher) run(task dispatchTask) {
+	defer func() {
+		if r := recover(); r != nil {
+			d.log.Errorf("Panic while handling message %s from %s in %s: %v\n%s", task.evt.Info.ID, task.evt.Info.Sender, task.evt.Info.Chat, r, debug.Stack())
+		}
+	}()
+	task.run()
+}
+
+// SetDispatchOptions changes how many message handlers run at once and how much may queue up
+func (wac *WhatsAppClient) SetDispatchOptions(opts DispatchOptions) {
+	wac.dispatcher.configure(opts)
+}
+
+// PendingMessages returns how many incoming messages are queued or being handled
+func (wac *WhatsAppClient) PendingMessages() int {
+	return wac.dispatcher.pending()
+}
@@ -0,0 +1,192 @@
+package whatsappclient
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/types/events"
+	waLog "go.mau.fi/whatsmeow/util/log"
+)
+
+func testEvent(chat string, id int) *events.Message {
+	return &events.Message{Info: types.MessageInfo{
+		ID:            fmt.Sprint(id),
+		MessageSource: types.MessageSource{Chat: types.NewJID(chat, types.DefaultUserServer)},
+	}}
+}
+
+func waitDispatched(t *testing.T, d *dispatcher) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := d.wait(ctx); err != nil {
+		t.Fatalf("handlers didn't finish: %v", err)
+	}
+}
+
+func TestDispatcherChatOrder(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    DispatchOptions
+		chats   int
+		perChat int
+	}{
+		{name: "one worker", opts: DispatchOptions{Workers: 1, MaxQueued: 1000}, chats: 3, perChat: 50},
+		{name: "more chats than workers", opts: DispatchOptions{Workers: 4, MaxQueued: 1000}, chats: 20, perChat: 30},
+		{name: "more workers than chats", opts: DispatchOptions{Workers: 64, MaxQueued: 1000}, chats: 4, perChat: 100},
+		{name: "blocking on a small queue", opts: DispatchOptions{Workers: 4, MaxQueued: 8, MaxQueuedPerChat: 2}, chats: 10, perChat: 40},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := newDispatcher(waLog.Noop, tt.opts)
+			var mu sync.Mutex
+			seen := make(map[string][]int)
+
+			for i := 0; i < tt.perChat; i++ {
+				for c := 0; c < tt.chats; c++ {
+					chat, seq := fmt.Sprint(1000+c), i
+					d.submit(testEvent(chat, seq), func() {
+						// Uneven handler times shuffle the chats against each other
+						time.Sleep(time.Duration(seq%3) * 100 * time.Microsecond)
+						mu.Lock()
+						seen[chat] = append(seen[chat], seq)
+						mu.Unlock()
+					})
+				}
+			}
+			waitDispatched(t, d)
+
+			if len(seen) != tt.chats {
+				t.Fatalf("handled %d chats, want %d", len(seen), tt.chats)
+			}
+			for chat, order := range seen {
+				if len(order) != tt.perChat {
+					t.Errorf("chat %s: handled %d messages, want %d", chat, len(order), tt.perChat)
+				}
+				for i, seq := range order {
+					if seq != i {
+						t.Errorf("chat %s: message %d handled at position %d", chat, seq, i)
+						break
+					}
+				}
+			}
+			if n := d.pending(); n != 0 {
+				t.Errorf("pending = %d after all handlers finished", n)
+			}
+		})
+	}
+}
+
+func TestDispatcherChatsRunInParallel(t *testing.T) {
+	d := newDispatcher(waLog.Noop, DispatchOptions{Workers: 2, MaxQueued: 10})
+	release := make(chan struct{})
+	otherDone := make(chan struct{})
+	sameDone := make(chan struct{})
+
+	d.submit(testEvent("1", 1), func() { <-release })
+	d.submit(testEvent("1", 2), func() { close(sameDone) })
+	d.submit(testEvent("2", 1), func() { close(otherDone) })
+
+	select {
+	case <-otherDone:
+	case <-time.After(5 * time.Second):
+		t.Fatal("a blocked chat held up another chat")
+	}
+	select {
+	case <-sameDone:
+		t.Fatal("a message ran before the earlier message of its chat finished")
+	default:
+	}
+	close(release)
+	waitDispatched(t, d)
+}
+
+func TestDispatcherOverflowDrop(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    DispatchOptions
+		chat    string
+		dropped bool
+	}{
+		{name: "total limit", opts: DispatchOptions{Workers: 1, MaxQueued: 2}, chat: "2", dropped: true},
+		{name: "per chat limit", opts: DispatchOptions{Workers: 1, MaxQueued: 10, MaxQueuedPerChat: 1}, chat: "1", dropped: true},
+		{name: "other chat under per chat limit", opts: DispatchOptions{Workers: 1, MaxQueued: 10, MaxQueuedPerChat: 1}, chat: "2", dropped: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var drops []*events.Message
+			tt.opts.Overflow = OverflowDrop
+			tt.opts.OnDrop = func(evt *events.Message) { drops = append(drops, evt) }
+			d := newDispatcher(waLog.Noop, tt.opts)
+
+			release := make(chan struct{})
+			started := make(chan struct{})
+			d.submit(testEvent("1", 1), func() {
+				close(started)
+				<-release
+			})
+			<-started
+			// The running message no longer counts against its chat's queue, the waiting one does
+			d.submit(testEvent("1", 2), func() {})
+
+			evt := testEvent(tt.chat, 3)
+			accepted := d.submit(evt, func() {})
+			if accepted == tt.dropped {
+				t.Errorf("accepted = %v, want %v", accepted, !tt.dropped)
+			}
+			if tt.dropped && (len(drops) != 1 || drops[0] != evt) {
+				t.Errorf("OnDrop got %v, want the dropped message", drops)
+			}
+			close(release)
+			waitDispatched(t, d)
+		})
+	}
+}
+
+func TestDispatcherRecoversPanics(t *testing.T) {
+	d := newDispatcher(waLog.Noop, DispatchOptions{Workers: 1, MaxQueued: 10})
+	handled := make(chan struct{})
+	d.submit(testEvent("1", 1), func() { panic("boom") })
+	d.submit(testEvent("1", 2), func() { close(handled) })
+
+	select {
+	case <-handled:
+	case <-time.After(5 * time.Second):
+		t.Fatal("a panicking handler stopped its chat")
+	}
+	waitDispatched(t, d)
+}
+
+func TestDispatcherClose(t *testing.T) {
+	d := newDispatcher(waLog.Noop, DispatchOptions{Workers: 1, MaxQueued: 10})
+	release := make(chan struct{})
+	var handled []int
+	d.submit(testEvent("1", 1), func() {
+		<-release
+		handled = append(handled, 1)
+	})
+	d.submit(testEvent("1", 2), func() { handled = append(handled, 2) })
+
+	d.close()
+	if d.submit(testEvent("1", 3), func() { handled = append(handled, 3) }) {
+		t.Error("submit accepted a message after close")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := d.wait(ctx); err == nil {
+		t.Error("wait returned while a handler was still running")
+	}
+
+	close(release)
+	waitDispatched(t, d)
+	if len(handled) != 2 || handled[0] != 1 || handled[1] != 2 {
+		t.Errorf("handled = %v, want the messages queued before close", handled)
+	}
+}
@@ -0,0 +1,179 @@
+package whatsappclient
+
+import (
+	messages "github.com/hacxk/easy-meow/Message"
+
+	"go.mau.fi/whatsmeow"
+	waProto "go.mau.fi/whatsmeow/binary/proto"
+	"go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/types/events"
+)
+
+// Subscription is a registered event handler that can be removed again
+type Subscription struct {
+	client *whatsmeow.Client
+	id     uint32
+}
+
+// Unsubscribe removes the handler. It reports whether it was still registered.
+func (s *Subscription) Unsubscribe() bool {
+	return s.client.RemoveEventHandler(s.id)
+}
+
+// Filter decides from the chat, sender and direction of an event whether a handler sees it
+type Filter func(src types.MessageSource) bool
+
+// GroupsOnly passes events from group chats
+func GroupsOnly() Filter {
+	return func(src types.MessageSource) bool {
+		return src.IsGroup
+	}
+}
+
+// PrivateOnly passes events from one-to-one chats
+func PrivateOnly() Filter {
+	return func(src types.MessageSource) bool {
+		return !src.IsGroup
+	}
+}
+
+// NotFromMe drops events caused by the logged-in account, such as its own messages sent from the phone
+func NotFromMe() Filter {
+	return func(src types.MessageSource) bool {
+		return !src.IsFromMe
+	}
+}
+
+// InChat passes events from the given chats
+func InChat(chats ...types.JID) Filter {
+	return func(src types.MessageSource) bool {
+		for _, chat := range chats {
+			if src.Chat.ToNonAD() == chat.ToNonAD() {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+func passes(src types.MessageSource, filters []Filter) bool {
+	for _, filter := range filters {
+		if !filter(src) {
+			return false
+		}
+	}
+	return true
+}
+
+// subscribe registers a handler for one event type
+func subscribe[T any](wac *WhatsAppClient, handler func(T)) *Subscription {
+	id := wac.client.AddEventHandler(func(evt interface{}) {
+		if typed, ok := evt.(T); ok {
+			handler(typed)
+		}
+	})
+	return &Subscription{client: wac.client.Client, id: id}
+}
+
+// OnMessage calls handler for every incoming message that passes the filters.
+// Like all message handlers it runs on the dispatch pool, in order within each chat.
+func (wac *WhatsAppClient) OnMessage(handler func(msg *messages.IncomingMessage), filters ...Filter) *Subscription {
+	return subscribe(wac, func(evt *events.Message) {
+		if passes(evt.Info.MessageSource, filters) {
+			wac.dispatcher.submit(evt, func() { handler(messages.NewIncomingMessage(evt)) })
+		}
+	})
+}
+
+// OnViewOnce calls handler for incoming view-once images, videos and voice notes that pass the filters.
+// Download them with DownloadMedia or SaveMedia before the sender's phone reports them as opened.
+func (wac *WhatsAppClient) OnViewOnce(handler func(msg *messages.IncomingMessage), filters ...Filter) *Subscription {
+	return wac.OnMessage(func(msg *messages.IncomingMessage) {
+		if msg.IsViewOnceMedia() {
+			handler(msg)
+		}
+	}, filters...)
+}
+
+// OnReceipt calls handler for delivery and read receipts that pass the filters
+func (wac *WhatsAppClient) OnReceipt(handler func(evt *events.Receipt), filters ...Filter) *Subscription {
+	return subscribe(wac, func(evt *events.Receipt) {
+		if passes(evt.MessageSource, filters) {
+			handler(evt)
+		}
+	})
+}
+
+// OnPresence calls handler when a contact comes online or goes offline.
+// Updates only arrive for contacts subscribed to with SubscribePresence.
+func (wac *WhatsAppClient) OnPresence(handler func(evt *events.Presence)) *Subscription {
+	return subscribe(wac, handler)
+}
+
+// OnGroupInfo calls handler when a group's name, topic, settings or participants change
+func (wac *WhatsAppClient) OnGroupInfo(handler func(evt *events.GroupInfo)) *Subscription {
+	return subscribe(wac, handler)
+}
+
+// OnJoinedGroup calls handler when the account is added to a group
+func (wac *WhatsAppClient) OnJoinedGroup(handler func(evt *events.JoinedGroup)) *Subscription {
+	return subscribe(wac, handler)
+}
+
+// OnCallOffer calls handler when someone starts calling the account
+func (wac *WhatsAppClient) OnCallOffer(handler func(evt *events.CallOffer)) *Subscription {
+	return subscribe(wac, handler)
+}
+
+// OnConnected calls handler every time the connection is established
+func (wac *WhatsAppClient) OnConnected(handler func(evt *events.Connected)) *Subscription {
+	return subscribe(wac, handler)
+}
+
+// OnDisconnected calls handler when the websocket is closed
+func (wac *WhatsAppClient) OnDisconnected(handler func(evt *events.Disconnected)) *Subscription {
+	return subscribe(wac, handler)
+}
+
+// OnLoggedOut calls handler when the device was unlinked from the phone or by the server
+func (wac *WhatsAppClient) OnLoggedOut(handler func(evt *events.LoggedOut)) *Subscription {
+	return subscribe(wac, handler)
+}
+
+// OnHistorySync calls handler with chat history sent by the phone after pairing
+func (wac *WhatsAppClient) OnHistorySync(handler func(evt *events.HistorySync)) *Subscription {
+	return subscribe(wac, handler)
+}
+
+// PollUpdate is a decrypted vote on a poll
+type PollUpdate struct {
+	// Message is the vote message itself, sent by the voter
+	Message *messages.IncomingMessage
+	// PollID is the ID of the poll that was voted on
+	PollID types.MessageID
+	// Vote holds the SHA-256 hashes of the selected option names. An empty list means the vote was retracted.
+	Vote *waProto.PollVoteMessage
+}
+
+// OnPollUpdate calls handler for votes on polls sent by this account.
+// WhatsApp delivers votes as encrypted messages rather than a separate event, so they are decrypted here;
+// votes that can't be decrypted, e.g. on polls created by others, are logged and skipped.
+func (wac *WhatsAppClient) OnPollUpdate(handler func(update *PollUpdate), filters ...Filter) *Subscription {
+	return subscribe(wac, func(evt *events.Message) {
+		if evt.Message.GetPollUpdateMessage() == nil || !passes(evt.Info.MessageSource, filters) {
+			return
+		}
+		wac.dispatcher.submit(evt, func() {
+			vote, err := wac.client.DecryptPollVote(evt)
+			if err != nil {
+				wac.client.Log.Warnf("Failed to decrypt poll vote %s: %v", evt.Info.ID, err)
+				return
+			}
+			handler(&PollUpdate{
+				Message: messages.NewIncomingMessage(evt),
+				PollID:  evt.Message.GetPollUpdateMessage().GetPollCreationMessageKey().GetID(),
+				Vote:    vote,
+			})
+		})
+	})
+}
@@ -0,0 +1,106 @@
+package whatsappclient
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// DefaultShutdownTimeout is how long Run waits for handlers and sends to finish after its context ends
+const DefaultShutdownTimeout = 30 * time.Second
+
+// tracker counts operations in progress so shutdown can wait until there are none
+type tracker struct {
+	mu   sync.Mutex
+	n    int
+	idle chan struct{}
+}
+
+func (t *tracker) add() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.n == 0 {
+		t.idle = make(chan struct{})
+	}
+	t.n++
+}
+
+func (t *tracker) done() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.n--
+	if t.n == 0 {
+		close(t.idle)
+	}
+}
+
+// wait blocks until nothing is in progress or ctx ends
+func (t *tracker) wait(ctx context.Context) error {
+	t.mu.Lock()
+	if t.n == 0 {
+		t.mu.Unlock()
+		return nil
+	}
+	idle := t.idle
+	t.mu.Unlock()
+
+	select {
+	case <-idle:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// SetShutdownTimeout changes how long Run waits for handlers and sends to finish. Zero waits forever.
+func (wac *WhatsAppClient) SetShutdownTimeout(timeout time.Duration) {
+	wac.shutdownTimeout = timeout
+}
+
+// Run connects if needed and blocks until ctx is cancelled. It then stops taking new messages, waits up to
+// the shutdown timeout for running handlers and outgoing sends, and disconnects. It returns nil after a clean
+// shutdown. The client can't handle messages again afterwards.
+func (wac *WhatsAppClient) Run(ctx context.Context) error {
+	if !wac.IsConnected() {
+		if err := wac.Connect(ctx); err != nil {
+			return err
+		}
+	}
+
+	<-ctx.Done()
+	return wac.shutdown()
+}
+
+// shutdown drains the dispatcher and in-flight sends, then disconnects
+func (wac *WhatsAppClient) shutdown() error {
+	wac.dispatcher.close()
+
+	drainCtx := context.Background()
+	if wac.shutdownTimeout > 0 {
+		var cancel context.CancelFunc
+		drainCtx, cancel = context.WithTimeout(drainCtx, wac.shutdownTimeout)
+		defer cancel()
+	}
+
+	var err error
+	if waitErr := wac.dispatcher.wait(drainCtx); waitErr != nil {
+		err = fmt.Errorf("gave up waiting for %d message handlers: %w", wac.dispatcher.pending(), waitErr)
+	} else if waitErr := wac.client.inflight.wait(drainCtx); waitErr != nil {
+		err = fmt.Errorf("gave up waiting for outgoing sends: %w", waitErr)
+	}
+
+	// Whatever is still running gets its context cancelled so it stops before the socket closes
+	wac.cancelHandlers()
+	wac.Disconnect()
+	return err
+}
+
+// NotifySignals returns a context that is cancelled on SIGINT or SIGTERM, for passing to Run.
+// Call stop to restore the default signal behaviour.
+func NotifySignals(parent context.Context) (ctx context.Context, stop context.CancelFunc) {
+	return signal.NotifyContext(parent, os.Interrupt, syscall.SIGTERM)
+}
@@ -0,0 +1,312 @@
+package whatsappclient
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	messages "github.com/hacxk/easy-meow/Message"
+
+	"github.com/mdp/qrterminal/v3"
+	"go.mau.fi/whatsmeow"
+	"rsc.io/qr"
+)
+
+// LoginPresenter shows the QR code or pairing code to whoever links the phone
+type LoginPresenter interface {
+	// ShowQR is called with every new QR code; the previous one stops working after its timeout
+	ShowQR(code string, timeout time.Duration) error
+	// ShowPairingCode is called with the 8-character code to enter under Linked devices > Link with phone number
+	ShowPairingCode(code string) error
+	// LoginFinished is called once with nil on success or the reason login failed
+	LoginFinished(err error)
+}
+
+// LoginOptions controls how a device without a stored session is linked
+type LoginOptions struct {
+	// Presenter shows the codes. Defaults to printing them to the terminal.
+	Presenter LoginPresenter
+	// PhoneNumber switches from QR codes to a pairing code for this number, in international format
+	PhoneNumber string
+	// ClientType and ClientDisplayName are shown on the phone when pairing by code.
+	// The name must look like "Browser (OS)" with a common browser and OS. Defaults to Chrome (Linux).
+	ClientType        whatsmeow.PairClientType
+	ClientDisplayName string
+	// NoRelinkOnLogout stops the client from starting a new login when the device is unlinked from the phone
+	NoRelinkOnLogout bool
+}
+
+// SetLoginOptions changes how Connect links the device when there is no stored session
+func (wac *WhatsAppClient) SetLoginOptions(opts LoginOptions) {
+	wac.loginOptions = opts
+}
+
+// login connects a new device and waits until it has been linked by QR or pairing code
+func (wac *WhatsAppClient) login(ctx context.Context) error {
+	opts := wac.loginOptions
+	presenter := opts.Presenter
+	if presenter == nil {
+		presenter = &TerminalPresenter{}
+	}
+
+	qrChan, err := wac.client.GetQRChannel(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get QR channel: %w", err)
+	}
+	if err := wac.client.Connect(); err != nil {
+		return fmt.Errorf("failed to connect: %w", err)
+	}
+
+	fail := func(err error) error {
+		presenter.LoginFinished(err)
+		wac.client.Disconnect()
+		return err
+	}
+
+	if opts.PhoneNumber != "" {
+		phone, err := messages.ParseRecipient(opts.PhoneNumber)
+		if err != nil {
+			return fail(fmt.Errorf("invalid phone number: %w", err))
+		}
+		clientType, displayName := opts.ClientType, opts.ClientDisplayName
+		if clientType == whatsmeow.PairClientUnknown {
+			clientType = whatsmeow.PairClientChrome
+		}
+		if displayName == "" {
+			displayName = "Chrome (Linux)"
+		}
+		code, err := wac.client.PairPhone(phone.User, true, clientType, displayName)
+		if err != nil {
+			return fail(fmt.Errorf("failed to request pairing code: %w", err))
+		}
+		if err := presenter.ShowPairingCode(code); err != nil {
+			return fail(fmt.Errorf("failed to show pairing code: %w", err))
+		}
+	}
+
+	for evt := range qrChan {
+		switch evt.Event {
+		case whatsmeow.QRChannelEventCode:
+			// QR codes keep coming while pairing by code, they just aren't needed
+			if opts.PhoneNumber != "" {
+				continue
+			}
+			if err := presenter.ShowQR(evt.Code, evt.Timeout); err != nil {
+				return fail(fmt.Errorf("failed to show QR code: %w", err))
+			}
+		case whatsmeow.QRChannelSuccess.Event:
+			presenter.LoginFinished(nil)
+			return nil
+		case whatsmeow.QRChannelEventError:
+			return fail(fmt.Errorf("login failed: %w", evt.Error))
+		default:
+			return fail(fmt.Errorf("login failed: %s", evt.Event))
+		}
+	}
+	if ctx.Err() != nil {
+		return fail(ctx.Err())
+	}
+	return fail(errors.New("login ended without linking the device"))
+}
+
+// TerminalPresenter prints QR codes and pairing codes to a terminal
+type TerminalPresenter struct {
+	// Out is where to print. Defaults to stdout.
+	Out io.Writer
+}
+
+func (p *TerminalPresenter) out() io.Writer {
+	if p.Out == nil {
+		return os.Stdout
+	}
+	return p.Out
+}
+
+func (p *TerminalPresenter) ShowQR(code string, timeout time.Duration) error {
+	qrterminal.GenerateHalfBlock(code, qrterminal.L, p.out())
+	_, err := fmt.Fprintln(p.out(), "Scan the QR code above to log in")
+	return err
+}
+
+func (p *TerminalPresenter) ShowPairingCode(code string) error {
+	_, err := fmt.Fprintf(p.out(), "Enter this code on your phone under Linked devices > Link with phone number: %s\n", code)
+	return err
+}
+
+func (p *TerminalPresenter) LoginFinished(err error) {
+	if err != nil {
+		fmt.Fprintln(p.out(), "Login failed:", err)
+	} else {
+		fmt.Fprintln(p.out(), "Logged in")
+	}
+}
+
+// PNGPresenter writes the QR code to a PNG file and the pairing code to a text file next to it,
+// e.g. on a volume an operator can open. Both are removed once login finishes.
+type PNGPresenter struct {
+	Path string
+}
+
+func (p *PNGPresenter) codePath() string {
+	return strings.TrimSuffix(p.Path, filepath.Ext(p.Path)) + ".txt"
+}
+
+func (p *PNGPresenter) ShowQR(code string, timeout time.Duration) error {
+	png, err := qrPNG(code)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(p.Path, png)
+}
+
+func (p *PNGPresenter) ShowPairingCode(code string) error {
+	return writeFileAtomic(p.codePath(), []byte(code+"\n"))
+}
+
+func (p *PNGPresenter) LoginFinished(err error) {
+	os.Remove(p.Path)
+	os.Remove(p.codePath())
+}
+
+// writeFileAtomic replaces path in one step so a viewer never sees a half-written image
+func writeFileAtomic(path string, data []byte) error {
+	temp := path + ".tmp"
+	if err := os.WriteFile(temp, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+	if err := os.Rename(temp, path); err != nil {
+		os.Remove(temp)
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+	return nil
+}
+
+func qrPNG(code string) ([]byte, error) {
+	encoded, err := qr.Encode(code, qr.L)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode QR code: %w", err)
+	}
+	encoded.Scale = 8
+	return encoded.PNG(), nil
+}
+
+// CallbackPresenter passes the codes to functions, e.g. to post them to a chat or a dashboard.
+// Nil functions are skipped.
+type CallbackPresenter struct {
+	OnQR          func(code string, timeout time.Duration) error
+	OnPairingCode func(code string) error
+	OnFinished    func(err error)
+}
+
+func (p *CallbackPresenter) ShowQR(code string, timeout time.Duration) error {
+	if p.OnQR == nil {
+		return nil
+	}
+	return p.OnQR(code, timeout)
+}
+
+func (p *CallbackPresenter) ShowPairingCode(code string) error {
+	if p.OnPairingCode == nil {
+		return nil
+	}
+	return p.OnPairingCode(code)
+}
+
+func (p *CallbackPresenter) LoginFinished(err error) {
+	if p.OnFinished != nil {
+		p.OnFinished(err)
+	}
+}
+
+// HTTPPresenter is an http.Handler serving a page with the current QR code or pairing code.
+// Mount it on your own server; the page refreshes itself until login finishes.
+type HTTPPresenter struct {
+	mu          sync.RWMutex
+	qrPNG       []byte
+	pairingCode string
+	finished    bool
+	err         error
+}
+
+var loginPage = template.Must(template.New("login").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>WhatsApp login</title>
+{{if not .Finished}}<meta http-equiv="refresh" content="5">{{end}}
+<style>body{font-family:sans-serif;text-align:center;margin-top:3em}code{font-size:2em;letter-spacing:.2em}</style>
+</head>
+<body>
+{{if .Finished}}
+  {{if .Err}}<p>Login failed: {{.Err}}</p>{{else}}<p>Logged in.</p>{{end}}
+{{else if .PairingCode}}
+  <p>On your phone open Linked devices &gt; Link with phone number and enter</p>
+  <p><code>{{.PairingCode}}</code></p>
+{{else if .QR}}
+  <p>Scan with WhatsApp on your phone</p>
+  <img src="data:image/png;base64,{{.QR}}" alt="QR code">
+{{else}}
+  <p>Waiting for a login code...</p>
+{{end}}
+</body>
+</html>
+`))
+
+func (p *HTTPPresenter) ShowQR(code string, timeout time.Duration) error {
+	png, err := qrPNG(code)
+	if err != nil {
+		return err
+	}
+	p.mu.Lock()
+	// A new login may follow a finished one, e.g. after the device was logged out
+	p.qrPNG, p.finished, p.err = png, false, nil
+	p.mu.Unlock()
+	return nil
+}
+
+func (p *HTTPPresenter) ShowPairingCode(code string) error {
+	p.mu.Lock()
+	p.pairingCode, p.finished, p.err = code, false, nil
+	p.mu.Unlock()
+	return nil
+}
+
+func (p *HTTPPresenter) LoginFinished(err error) {
+	p.mu.Lock()
+	p.finished, p.err = true, err
+	p.qrPNG, p.pairingCode = nil, ""
+	p.mu.Unlock()
+}
+
+func (p *HTTPPresenter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	p.mu.RLock()
+	data := struct {
+		QR          string
+		PairingCode string
+		Finished    bool
+		Err         error
+	}{
+		PairingCode: p.pairingCode,
+		Finished:    p.finished,
+		Err:         p.err,
+	}
+	if p.qrPNG != nil {
+		data.QR = base64.StdEncoding.EncodeToString(p.qrPNG)
+	}
+	p.mu.RUnlock()
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	if err := loginPage.Execute(w, data); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
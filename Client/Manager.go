@@ -0,0 +1,276 @@
+package whatsappclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	messages "github.com/hacxk/easy-meow/Message"
+
+	"go.mau.fi/whatsmeow/store"
+	"go.mau.fi/whatsmeow/store/sqlstore"
+	"go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/types/events"
+)
+
+// ErrUnknownAccount is returned for accounts the manager doesn't have
+var ErrUnknownAccount = errors.New("unknown account")
+
+// AccountEvent is a whatsmeow event along with the account that received it
+type AccountEvent struct {
+	// Account is the JID of the receiving account, without device part.
+	// It is empty for events of a session that is still being paired.
+	Account types.JID
+	Client  *WhatsAppClient
+	Event   interface{}
+}
+
+// Manager runs several WhatsApp accounts from one session store
+type Manager struct {
+	cfg       *clientConfig
+	container *sqlstore.Container
+	dbPath    string
+
+	mu       sync.RWMutex
+	accounts map[types.JID]*WhatsAppClient
+	handlers []func(evt *AccountEvent)
+}
+
+// NewManager opens the store (the sqlite file at dbPath unless options say otherwise) and loads every device in it.
+// The accounts aren't connected until Connect, ConnectAll or Run.
+func NewManager(dbPath string, opts ...Option) (*Manager, error) {
+	cfg := newClientConfig(dbPath, opts)
+	container, err := cfg.openContainer()
+	if err != nil {
+		return nil, err
+	}
+	cfg.applyDeviceName()
+
+	m := &Manager{
+		cfg:       cfg,
+		container: container,
+		dbPath:    dbPath,
+		accounts:  make(map[types.JID]*WhatsAppClient),
+	}
+
+	devices, err := container.GetAllDevices()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get devices from store: %w", err)
+	}
+	for _, device := range devices {
+		wac := m.newAccount(device, device.ID.User)
+		m.accounts[device.ID.ToNonAD()] = wac
+	}
+	return m, nil
+}
+
+// newAccount creates a client for a device and forwards its events to the manager's handlers
+func (m *Manager) newAccount(device *store.Device, name string) *WhatsAppClient {
+	wac := newWhatsAppClient(m.dbPath, m.container, device, m.cfg.newLogger("WhatsApp/"+name))
+	// A relinked account would come back under a new JID, so the manager leaves pairing to Add
+	wac.loginOptions.NoRelinkOnLogout = true
+	wac.AddEventHandler(func(evt interface{}) {
+		m.dispatch(wac, evt)
+	})
+	return wac
+}
+
+func (m *Manager) dispatch(wac *WhatsAppClient, evt interface{}) {
+	var account types.JID
+	if id := wac.client.Store.ID; id != nil {
+		account = id.ToNonAD()
+	}
+	if _, ok := evt.(*events.LoggedOut); ok {
+		// The session is gone (whatsmeow may already have cleared the ID), so the account can't come back without pairing again
+		m.forget(wac)
+	}
+
+	m.mu.RLock()
+	handlers := append([](func(evt *AccountEvent))(nil), m.handlers...)
+	m.mu.RUnlock()
+
+	accountEvent := &AccountEvent{Account: account, Client: wac, Event: evt}
+	for _, handler := range handlers {
+		handler(accountEvent)
+	}
+}
+
+// AddEventHandler registers a handler for the events of every account
+func (m *Manager) AddEventHandler(handler func(evt *AccountEvent)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.handlers = append(m.handlers, handler)
+}
+
+// Accounts returns the JIDs of all loaded accounts
+func (m *Manager) Accounts() []types.JID {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	jids := make([]types.JID, 0, len(m.accounts))
+	for jid := range m.accounts {
+		jids = append(jids, jid)
+	}
+	return jids
+}
+
+// Account returns the client of an account by JID or phone number
+func (m *Manager) Account(account string) (*WhatsAppClient, error) {
+	jid, err := messages.ParseRecipient(account)
+	if err != nil {
+		return nil, err
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	wac, ok := m.accounts[jid.ToNonAD()]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownAccount, jid)
+	}
+	return wac, nil
+}
+
+// Client returns the ExtendedClient of an account by JID or phone number, for sending
+func (m *Manager) Client(account string) (*ExtendedClient, error) {
+	wac, err := m.Account(account)
+	if err != nil {
+		return nil, err
+	}
+	return wac.GetClient(), nil
+}
+
+// Add pairs a new account using the given login options and returns it once it is linked and connected.
+// If pairing fails the new client is shut down and nothing is added.
+func (m *Manager) Add(ctx context.Context, login LoginOptions) (*WhatsAppClient, error) {
+	wac := m.newAccount(m.container.NewDevice(), "new")
+	login.NoRelinkOnLogout = true
+	wac.SetLoginOptions(login)
+	if err := wac.Connect(ctx); err != nil {
+		// The client was never added, so nothing else would ever stop it
+		wac.cancelHandlers()
+		wac.client.RemoveEventHandlers()
+		wac.Disconnect()
+		return nil, err
+	}
+
+	jid := wac.client.Store.ID.ToNonAD()
+	m.mu.Lock()
+	if existing, ok := m.accounts[jid]; ok && existing != wac {
+		existing.Disconnect()
+	}
+	m.accounts[jid] = wac
+	m.mu.Unlock()
+	return wac, nil
+}
+
+// Connect connects one account
+func (m *Manager) Connect(ctx context.Context, account string) error {
+	wac, err := m.Account(account)
+	if err != nil {
+		return err
+	}
+	return wac.Connect(ctx)
+}
+
+// ConnectAll connects every loaded account, returning the errors of those that failed
+func (m *Manager) ConnectAll(ctx context.Context) error {
+	var errs []error
+	for _, wac := range m.snapshot() {
+		if wac.IsConnected() {
+			continue
+		}
+		if err := wac.Connect(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", wac.client.Store.ID, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Remove disconnects an account and deletes its session from the store without unlinking it on the phone
+func (m *Manager) Remove(account string) error {
+	wac, err := m.Account(account)
+	if err != nil {
+		return err
+	}
+	wac.Disconnect()
+	if err := wac.client.Store.Delete(); err != nil {
+		return fmt.Errorf("failed to delete device: %w", err)
+	}
+	m.forget(wac)
+	return nil
+}
+
+// Logout unlinks an account from the phone and deletes its session
+func (m *Manager) Logout(ctx context.Context, account string) error {
+	wac, err := m.Account(account)
+	if err != nil {
+		return err
+	}
+	if err := wac.Logout(ctx); err != nil {
+		return err
+	}
+	m.forget(wac)
+	return nil
+}
+
+// Run connects every account, blocks until ctx is cancelled and then shuts all of them down like WhatsAppClient.Run.
+// Accounts that fail to connect don't stop the others; their errors are returned along with any shutdown errors.
+// If none of the accounts connects, Run shuts down and returns right away.
+func (m *Manager) Run(ctx context.Context) error {
+	connectErr := m.ConnectAll(ctx)
+	if connectErr == nil || m.anyConnected() {
+		<-ctx.Done()
+	}
+	return errors.Join(connectErr, m.shutdown())
+}
+
+// shutdown stops every account in parallel, waiting for their handlers and sends like WhatsAppClient.Run
+func (m *Manager) shutdown() error {
+	accounts := m.snapshot()
+	errs := make([]error, len(accounts))
+	var wg sync.WaitGroup
+	for i, wac := range accounts {
+		wg.Add(1)
+		go func(i int, wac *WhatsAppClient) {
+			defer wg.Done()
+			errs[i] = wac.shutdown()
+		}(i, wac)
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+// Disconnect disconnects every account
+func (m *Manager) Disconnect() {
+	for _, wac := range m.snapshot() {
+		wac.Disconnect()
+	}
+}
+
+func (m *Manager) anyConnected() bool {
+	for _, wac := range m.snapshot() {
+		if wac.IsConnected() {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *Manager) snapshot() []*WhatsAppClient {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	accounts := make([]*WhatsAppClient, 0, len(m.accounts))
+	for _, wac := range m.accounts {
+		accounts = append(accounts, wac)
+	}
+	return accounts
+}
+
+func (m *Manager) forget(wac *WhatsAppClient) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for jid, existing := range m.accounts {
+		if existing == wac {
+			delete(m.accounts, jid)
+		}
+	}
+}
@@ -0,0 +1,181 @@
+package whatsappclient
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+	"sync"
+
+	messages "github.com/hacxk/easy-meow/Message"
+
+	"go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/types/events"
+)
+
+// Context is what message handlers and middleware receive: the client to answer with,
+// the parsed message and a place to pass values down the chain
+type Context struct {
+	Ctx     context.Context
+	Client  *ExtendedClient
+	Message *messages.IncomingMessage
+
+	mu     sync.RWMutex
+	values map[string]interface{}
+}
+
+// NewContext creates a handler context for a message
+func NewContext(ctx context.Context, client *ExtendedClient, msg *messages.IncomingMessage) *Context {
+	return &Context{Ctx: ctx, Client: client, Message: msg}
+}
+
+// Set stores a value for handlers further down the chain
+func (c *Context) Set(key string, value interface{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.values == nil {
+		c.values = make(map[string]interface{})
+	}
+	c.values[key] = value
+}
+
+// Get returns a value stored by an earlier middleware
+func (c *Context) Get(key string) (interface{}, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	value, ok := c.values[key]
+	return value, ok
+}
+
+// Reply answers the message being handled
+func (c *Context) Reply(text string) error {
+	_, err := c.Client.Reply(c.Ctx, c.Message, text)
+	return err
+}
+
+// HandlerFunc handles an incoming message
+type HandlerFunc func(c *Context) error
+
+// Middleware wraps a handler. It stops the chain by returning without calling next.
+type Middleware func(next HandlerFunc) HandlerFunc
+
+// Chain wraps handler in middleware so that the first middleware runs first
+func Chain(handler HandlerFunc, middleware ...Middleware) HandlerFunc {
+	for i := len(middleware) - 1; i >= 0; i-- {
+		handler = middleware[i](handler)
+	}
+	return handler
+}
+
+// messagePipeline holds the global middleware and the message routes of a WhatsAppClient
+type messagePipeline struct {
+	mu         sync.RWMutex
+	middleware []Middleware
+	routes     []HandlerFunc
+	installed  bool
+}
+
+// Use adds middleware that runs, in registration order, for every incoming message before any route
+func (wac *WhatsAppClient) Use(middleware ...Middleware) {
+	wac.pipeline.mu.Lock()
+	wac.pipeline.middleware = append(wac.pipeline.middleware, middleware...)
+	wac.pipeline.mu.Unlock()
+	wac.installPipeline()
+}
+
+// HandleMessage adds a message route with its own middleware. Routes run in registration order
+// after the global middleware; an error from one route is logged and doesn't stop the others.
+func (wac *WhatsAppClient) HandleMessage(handler HandlerFunc, middleware ...Middleware) {
+	wac.pipeline.mu.Lock()
+	wac.pipeline.routes = append(wac.pipeline.routes, Chain(handler, middleware...))
+	wac.pipeline.mu.Unlock()
+	wac.installPipeline()
+}
+
+// installPipeline registers the whatsmeow event handler that feeds the pipeline, once
+func (wac *WhatsAppClient) installPipeline() {
+	wac.pipeline.mu.Lock()
+	defer wac.pipeline.mu.Unlock()
+	if wac.pipeline.installed {
+		return
+	}
+	wac.pipeline.installed = true
+	wac.client.AddEventHandler(func(evt interface{}) {
+		if msg, ok := evt.(*events.Message); ok {
+			wac.dispatcher.submit(msg, func() { wac.handleMessage(wac.handlerCtx, msg) })
+		}
+	})
+}
+
+// handleMessage runs one message through the global middleware and every route
+func (wac *WhatsAppClient) handleMessage(ctx context.Context, evt *events.Message) {
+	wac.pipeline.mu.RLock()
+	middleware := append([]Middleware(nil), wac.pipeline.middleware...)
+	routes := append([]HandlerFunc(nil), wac.pipeline.routes...)
+	wac.pipeline.mu.RUnlock()
+
+	dispatch := func(c *Context) error {
+		for _, route := range routes {
+			if err := route(c); err != nil {
+				c.Client.Log.Errorf("Message handler failed for %s in %s: %v", c.Message.ID(), c.Message.Chat(), err)
+			}
+		}
+		return nil
+	}
+
+	c := NewContext(ctx, wac.client, messages.NewIncomingMessage(evt))
+	if err := Chain(dispatch, middleware...)(c); err != nil {
+		wac.client.Log.Errorf("Message middleware failed for %s in %s: %v", c.Message.ID(), c.Message.Chat(), err)
+	}
+}
+
+// IgnoreFromMe stops messages sent by the logged-in account
+func IgnoreFromMe() Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(c *Context) error {
+			if c.Message.IsFromMe() {
+				return nil
+			}
+			return next(c)
+		}
+	}
+}
+
+// AllowSenders only lets messages from the given users through, e.g. to restrict admin commands
+func AllowSenders(users ...types.JID) Middleware {
+	allowed := make(map[string]struct{}, len(users))
+	for _, user := range users {
+		allowed[user.ToNonAD().String()] = struct{}{}
+	}
+	return func(next HandlerFunc) HandlerFunc {
+		return func(c *Context) error {
+			if _, ok := allowed[c.Message.Sender().ToNonAD().String()]; !ok {
+				return nil
+			}
+			return next(c)
+		}
+	}
+}
+
+// LogMessages logs every message that reaches it
+func LogMessages() Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(c *Context) error {
+			c.Client.Log.Infof("Received %s message %s from %s in %s", c.Message.Type(), c.Message.ID(), c.Message.Sender(), c.Message.Chat())
+			return next(c)
+		}
+	}
+}
+
+// Recover turns a panic further down the chain into an error, so one bad handler can't crash the bot
+func Recover() Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(c *Context) (err error) {
+			defer func() {
+				if r := recover(); r != nil {
+					err = fmt.Errorf("panic in message handler: %v\n%s", r, debug.Stack())
+				}
+			}()
+			return next(c)
+		}
+	}
+}
@@ -0,0 +1,616 @@
+package whatsappclient
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	messages "github.com/hacxk/easy-meow/Message"
+
+	"go.mau.fi/whatsmeow/types"
+)
+
+// ArgType is how a command argument is parsed and validated
+type ArgType int
+
+const (
+	// ArgString is a single word or quoted string
+	ArgString ArgType = iota
+	// ArgInt is a whole number
+	ArgInt
+	// ArgDuration is a Go duration such as 90s or 1h30m
+	ArgDuration
+	// ArgJID is a JID or phone number
+	ArgJID
+	// ArgMention is an @mention of a user in the message
+	ArgMention
+	// ArgRest takes the remaining text as-is. It must be the last argument.
+	ArgRest
+)
+
+// Arg describes one positional argument of a command
+type Arg struct {
+	Name     string
+	Type     ArgType
+	Optional bool
+	// Validate can reject a parsed value with a message that is shown to the user
+	Validate func(value interface{}) error
+}
+
+// CommandHandler runs a matched command
+type CommandHandler func(cmd *CommandContext) error
+
+// Command is a chat command such as "!ban @user 1h"
+type Command struct {
+	Name        string
+	Aliases     []string
+	Description string
+	Args        []Arg
+	Handler     CommandHandler
+	// Middleware runs, in order, only for this command after its arguments are parsed
+	Middleware []Middleware
+
+	parent      *Command
+	subcommands []*Command
+	// router is set once the command is registered, so later sub-commands are added under its lock
+	router *Router
+}
+
+// Sub adds a sub-command, e.g. "!group add" under "!group", and returns the parent for chaining.
+// Register checks the sub-commands of a new command; adding an invalid one to a command that is
+// already registered panics like MustRegister.
+func (c *Command) Sub(sub *Command) *Command {
+	if r := c.router; r != nil {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		if err := c.validateSub(sub); err != nil {
+			panic(err)
+		}
+		sub.attach(r)
+	}
+	sub.parent = c
+	c.subcommands = append(c.subcommands, sub)
+	return c
+}
+
+// validateSub checks that sub is a valid command whose names don't clash with the other sub-commands of c
+func (c *Command) validateSub(sub *Command) error {
+	if err := checkSubName(c, c.subcommands, sub); err != nil {
+		return err
+	}
+	return validateCommand(sub)
+}
+
+// checkSubName checks that sub has a name and that neither it nor an alias is taken by one of siblings
+func checkSubName(parent *Command, siblings []*Command, sub *Command) error {
+	if sub.Name == "" {
+		return fmt.Errorf("command %q: sub-command has no name", parent.FullName())
+	}
+	for _, name := range append([]string{sub.Name}, sub.Aliases...) {
+		for _, existing := range siblings {
+			if existing.matches(name) {
+				return fmt.Errorf("command %q: sub-command %q is already registered", parent.FullName(), name)
+			}
+		}
+	}
+	return nil
+}
+
+// attach marks cmd and its sub-commands as registered with r
+func (c *Command) attach(r *Router) {
+	c.router = r
+	for _, sub := range c.subcommands {
+		sub.attach(r)
+	}
+}
+
+// FullName is the command name including its parents, e.g. "group add"
+func (c *Command) FullName() string {
+	if c.parent == nil {
+		return c.Name
+	}
+	return c.parent.FullName() + " " + c.Name
+}
+
+// Usage returns the command line syntax, e.g. "!ban <user> [duration]"
+func (c *Command) Usage(prefix string) string {
+	parts := []string{prefix + c.FullName()}
+	for _, arg := range c.Args {
+		name := arg.Name
+		if arg.Type == ArgRest {
+			name += "..."
+		}
+		if arg.Optional {
+			parts = append(parts, "["+name+"]")
+		} else {
+			parts = append(parts, "<"+name+">")
+		}
+	}
+	if len(c.subcommands) > 0 && c.Handler == nil {
+		names := make([]string, len(c.subcommands))
+		for i, sub := range c.subcommands {
+			names[i] = sub.Name
+		}
+		parts = append(parts, "<"+strings.Join(names, "|")+">")
+	}
+	return strings.Join(parts, " ")
+}
+
+func (c *Command) matches(name string) bool {
+	if strings.EqualFold(c.Name, name) {
+		return true
+	}
+	for _, alias := range c.Aliases {
+		if strings.EqualFold(alias, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// UsageError is returned by argument parsing and can be returned by handlers to reply with the command usage
+type UsageError struct {
+	Message string
+}
+
+func (e *UsageError) Error() string {
+	return e.Message
+}
+
+// CommandContext is what a command handler receives: the handler context of the message plus the parsed command
+type CommandContext struct {
+	*Context
+	Command *Command
+	// Prefix is the prefix the user typed, so replies can mention other commands the same way
+	Prefix string
+	// RawArgs are the split arguments before type parsing
+	RawArgs []string
+
+	args map[string]interface{}
+}
+
+// Has reports whether an optional argument was given
+func (c *CommandContext) Has(name string) bool {
+	_, ok := c.args[name]
+	return ok
+}
+
+// String returns a string or rest argument
+func (c *CommandContext) String(name string) string {
+	value, _ := c.args[name].(string)
+	return value
+}
+
+// Int returns an integer argument
+func (c *CommandContext) Int(name string) int {
+	value, _ := c.args[name].(int)
+	return value
+}
+
+// Duration returns a duration argument
+func (c *CommandContext) Duration(name string) time.Duration {
+	value, _ := c.args[name].(time.Duration)
+	return value
+}
+
+// JID returns a JID or mention argument
+func (c *CommandContext) JID(name string) types.JID {
+	value, _ := c.args[name].(types.JID)
+	return value
+}
+
+// Router dispatches incoming messages that start with a prefix to registered commands
+type Router struct {
+	mu           sync.RWMutex
+	prefixes     []string
+	chatPrefixes map[types.JID][]string
+	commands     []*Command
+
+	// AllowFromMe also runs commands sent by the logged-in account
+	AllowFromMe bool
+	// OnError is called with errors returned by handlers. By default they are logged.
+	OnError func(cmd *CommandContext, err error)
+}
+
+// NewRouter creates a router with the given default prefixes ("!" when none are given) and a built-in help command
+func NewRouter(prefixes ...string) *Router {
+	if len(prefixes) == 0 {
+		prefixes = []string{"!"}
+	}
+	r := &Router{
+		prefixes:     prefixes,
+		chatPrefixes: make(map[types.JID][]string),
+	}
+	r.commands = append(r.commands, &Command{
+		Name:        "help",
+		Description: "Show the available commands",
+		Args:        []Arg{{Name: "command", Type: ArgRest, Optional: true}},
+		Handler:     r.helpCommand,
+	})
+	return r
+}
+
+// SetChatPrefixes overrides the prefixes for one chat. Passing none restores the defaults.
+func (r *Router) SetChatPrefixes(chat types.JID, prefixes ...string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(prefixes) == 0 {
+		delete(r.chatPrefixes, chat.ToNonAD())
+		return
+	}
+	r.chatPrefixes[chat.ToNonAD()] = prefixes
+}
+
+// Prefixes returns the prefixes used in a chat
+func (r *Router) Prefixes(chat types.JID) []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if prefixes, ok := r.chatPrefixes[chat.ToNonAD()]; ok {
+		return prefixes
+	}
+	return r.prefixes
+}
+
+// Register adds a command. Names and aliases must be unique, also among the sub-commands of a command.
+func (r *Router) Register(cmd *Command) error {
+	if cmd.Name == "" {
+		return fmt.Errorf("command has no name")
+	}
+	if cmd.router != nil {
+		return fmt.Errorf("command %q is already registered", cmd.FullName())
+	}
+	if err := validateCommand(cmd); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, name := range append([]string{cmd.Name}, cmd.Aliases...) {
+		for _, existing := range r.commands {
+			if existing.matches(name) {
+				return fmt.Errorf("command %q is already registered", name)
+			}
+		}
+	}
+	cmd.attach(r)
+	r.commands = append(r.commands, cmd)
+	return nil
+}
+
+// MustRegister is Register for setup code, panicking on duplicate names
+func (r *Router) MustRegister(cmd *Command) {
+	if err := r.Register(cmd); err != nil {
+		panic(err)
+	}
+}
+
+// validateCommand checks the arguments of cmd and the names and arguments of its sub-commands
+func validateCommand(cmd *Command) error {
+	for i, arg := range cmd.Args {
+		if arg.Type == ArgRest && i != len(cmd.Args)-1 {
+			return fmt.Errorf("command %q: rest argument %q must be last", cmd.FullName(), arg.Name)
+		}
+		if !arg.Optional && i > 0 && cmd.Args[i-1].Optional {
+			return fmt.Errorf("command %q: required argument %q follows an optional one", cmd.FullName(), arg.Name)
+		}
+	}
+	for i, sub := range cmd.subcommands {
+		if err := checkSubName(cmd, cmd.subcommands[:i], sub); err != nil {
+			return err
+		}
+		if err := validateCommand(sub); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Dispatch runs the command in the message being handled, if any. It reports whether the message was a command.
+func (r *Router) Dispatch(c *Context) bool {
+	msg := c.Message
+	if msg.IsFromMe() && !r.AllowFromMe {
+		return false
+	}
+
+	text := strings.TrimSpace(msg.Text())
+	prefix, body, ok := r.matchPrefix(msg.Chat(), text)
+	if !ok {
+		return false
+	}
+
+	words, offsets, err := splitArgs(body)
+	if err != nil || len(words) == 0 {
+		return false
+	}
+
+	r.mu.RLock()
+	var cmd *Command
+	for _, candidate := range r.commands {
+		if candidate.matches(words[0]) {
+			cmd = candidate
+			break
+		}
+	}
+	// Walk down into sub-commands as long as the next word names one
+	if cmd != nil {
+		words, offsets = words[1:], offsets[1:]
+		for len(words) > 0 {
+			var next *Command
+			for _, sub := range cmd.subcommands {
+				if sub.matches(words[0]) {
+					next = sub
+					break
+				}
+			}
+			if next == nil {
+				break
+			}
+			cmd, words, offsets = next, words[1:], offsets[1:]
+		}
+	}
+	r.mu.RUnlock()
+	if cmd == nil {
+		return false
+	}
+
+	cmdCtx := &CommandContext{
+		Context: c,
+		Command: cmd,
+		Prefix:  prefix,
+		RawArgs: words,
+	}
+
+	if cmd.Handler == nil {
+		r.replyUsage(cmdCtx, &UsageError{Message: "Missing sub-command"})
+		return true
+	}
+
+	cmdCtx.args, err = parseArgs(cmd, msg, words, offsets, body)
+	if err == nil {
+		handler := Chain(func(*Context) error { return cmd.Handler(cmdCtx) }, cmd.Middleware...)
+		err = handler(c)
+	}
+
+	var usageErr *UsageError
+	switch {
+	case err == nil:
+	case errors.As(err, &usageErr):
+		r.replyUsage(cmdCtx, usageErr)
+	case r.OnError != nil:
+		r.OnError(cmdCtx, err)
+	default:
+		c.Client.Log.Errorf("Command %s failed: %v", cmd.FullName(), err)
+	}
+	return true
+}
+
+func (r *Router) matchPrefix(chat types.JID, text string) (prefix string, body string, ok bool) {
+	prefixes := r.Prefixes(chat)
+	// Try longer prefixes first so "!!" wins over "!"
+	sorted := append([]string(nil), prefixes...)
+	sort.Slice(sorted, func(i, j int) bool { return len(sorted[i]) > len(sorted[j]) })
+	for _, prefix := range sorted {
+		if strings.HasPrefix(text, prefix) {
+			body := strings.TrimLeftFunc(text[len(prefix):], unicode.IsSpace)
+			if prefix != "" || body != "" {
+				return prefix, body, true
+			}
+		}
+	}
+	return "", "", false
+}
+
+func (r *Router) replyUsage(cmd *CommandContext, usageErr *UsageError) {
+	// Usage lists sub-commands, which Sub may be adding to
+	r.mu.RLock()
+	usage := cmd.Command.Usage(cmd.Prefix)
+	r.mu.RUnlock()
+	text := fmt.Sprintf("%s\nUsage: %s", usageErr.Message, usage)
+	if err := cmd.Reply(text); err != nil {
+		cmd.Client.Log.Warnf("Failed to send usage of %s: %v", cmd.Command.FullName(), err)
+	}
+}
+
+// Help lists the commands with their usage and description, as shown by the built-in help command
+func (r *Router) Help(prefix string) string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var lines []string
+	var add func(cmd *Command)
+	add = func(cmd *Command) {
+		if cmd.Handler != nil {
+			line := cmd.Usage(prefix)
+			if cmd.Description != "" {
+				line += " - " + cmd.Description
+			}
+			if len(cmd.Aliases) > 0 {
+				line += fmt.Sprintf(" (aliases: %s)", strings.Join(cmd.Aliases, ", "))
+			}
+			lines = append(lines, line)
+		}
+		for _, sub := range cmd.subcommands {
+			add(sub)
+		}
+	}
+	for _, cmd := range r.commands {
+		add(cmd)
+	}
+	return "Commands:\n" + strings.Join(lines, "\n")
+}
+
+func (r *Router) helpCommand(cmd *CommandContext) error {
+	name := cmd.String("command")
+	if name == "" {
+		return cmd.Reply(r.Help(cmd.Prefix))
+	}
+
+	words, _ := SplitArgs(name)
+	r.mu.RLock()
+	var found *Command
+	for _, candidate := range r.commands {
+		if len(words) > 0 && candidate.matches(words[0]) {
+			found = candidate
+			break
+		}
+	}
+	if found == nil {
+		r.mu.RUnlock()
+		return cmd.Reply(fmt.Sprintf("Unknown command %q", name))
+	}
+	for _, word := range words[1:] {
+		for _, sub := range found.subcommands {
+			if sub.matches(word) {
+				found = sub
+				break
+			}
+		}
+	}
+	text := "Usage: " + found.Usage(cmd.Prefix)
+	r.mu.RUnlock()
+
+	if found.Description != "" {
+		text += "\n" + found.Description
+	}
+	return cmd.Reply(text)
+}
+
+// SplitArgs splits a command line on whitespace, keeping "double" or 'single' quoted strings together.
+// A backslash escapes the next character.
+func SplitArgs(line string) ([]string, error) {
+	args, _, err := splitArgs(line)
+	return args, err
+}
+
+// splitArgs is SplitArgs that also returns the byte offset each argument starts at
+func splitArgs(line string) ([]string, []int, error) {
+	var args []string
+	var offsets []int
+	var current strings.Builder
+	var quote rune
+	inArg, escaped := false, false
+
+	start := func(i int) {
+		if !inArg {
+			offsets = append(offsets, i)
+			inArg = true
+		}
+	}
+
+	for i, r := range line {
+		switch {
+		case escaped:
+			current.WriteRune(r)
+			escaped = false
+		case r == '\\':
+			start(i)
+			escaped = true
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				current.WriteRune(r)
+			}
+		case r == '"' || r == '\'':
+			start(i)
+			quote = r
+		case unicode.IsSpace(r):
+			if inArg {
+				args = append(args, current.String())
+				current.Reset()
+				inArg = false
+			}
+		default:
+			start(i)
+			current.WriteRune(r)
+		}
+	}
+	if quote != 0 {
+		return nil, nil, fmt.Errorf("unterminated quote")
+	}
+	if inArg {
+		args = append(args, current.String())
+	}
+	return args, offsets, nil
+}
+
+// parseArgs converts the raw words into typed values according to the command's Args
+func parseArgs(cmd *Command, msg *messages.IncomingMessage, words []string, offsets []int, body string) (map[string]interface{}, error) {
+	values := make(map[string]interface{})
+	for i, arg := range cmd.Args {
+		if arg.Type == ArgRest {
+			if len(words) <= i {
+				if !arg.Optional {
+					return nil, &UsageError{Message: fmt.Sprintf("Missing %s", arg.Name)}
+				}
+				break
+			}
+			// The rest is taken as typed, keeping the user's spacing and quotes
+			values[arg.Name] = strings.TrimSpace(body[offsets[i]:])
+			words = words[:i]
+			break
+		}
+
+		if i >= len(words) {
+			if arg.Optional {
+				continue
+			}
+			return nil, &UsageError{Message: fmt.Sprintf("Missing %s", arg.Name)}
+		}
+
+		value, err := parseArg(arg, words[i], msg)
+		if err != nil {
+			return nil, &UsageError{Message: fmt.Sprintf("Invalid %s: %v", arg.Name, err)}
+		}
+		if arg.Validate != nil {
+			if err := arg.Validate(value); err != nil {
+				return nil, &UsageError{Message: fmt.Sprintf("Invalid %s: %v", arg.Name, err)}
+			}
+		}
+		values[arg.Name] = value
+	}
+
+	hasRest := len(cmd.Args) > 0 && cmd.Args[len(cmd.Args)-1].Type == ArgRest
+	if !hasRest && len(words) > len(cmd.Args) {
+		return nil, &UsageError{Message: "Too many arguments"}
+	}
+	return values, nil
+}
+
+func parseArg(arg Arg, word string, msg *messages.IncomingMessage) (interface{}, error) {
+	switch arg.Type {
+	case ArgInt:
+		return strconv.Atoi(word)
+	case ArgDuration:
+		return time.ParseDuration(word)
+	case ArgJID:
+		return messages.ParseRecipient(word)
+	case ArgMention:
+		user := strings.TrimPrefix(word, "@")
+		if !strings.HasPrefix(word, "@") || user == "" {
+			return nil, fmt.Errorf("expected an @mention")
+		}
+		// Prefer the JID WhatsApp attached to the mention, since the text may show a saved name
+		for _, mentioned := range msg.Mentions() {
+			if mentioned.User == user {
+				return mentioned, nil
+			}
+		}
+		return messages.ParseRecipient(user)
+	default:
+		return word, nil
+	}
+}
+
+// UseRouter adds the router as a message route, behind the global middleware and the given route middleware
+func (wac *WhatsAppClient) UseRouter(router *Router, middleware ...Middleware) {
+	wac.HandleMessage(func(c *Context) error {
+		router.Dispatch(c)
+		return nil
+	}, middleware...)
+}
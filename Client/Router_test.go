@@ -0,0 +1,289 @@
+package whatsappclient
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	messages "github.com/hacxk/easy-meow/Message"
+
+	waProto "go.mau.fi/whatsmeow/binary/proto"
+	"go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/types/events"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestSplitArgs(t *testing.T) {
+	tests := []struct {
+		line    string
+		args    []string
+		offsets []int
+		err     bool
+	}{
+		{line: "", args: nil, offsets: nil},
+		{line: "   ", args: nil, offsets: nil},
+		{line: "ban alice 1h", args: []string{"ban", "alice", "1h"}, offsets: []int{0, 4, 10}},
+		{line: "  spaced   out  ", args: []string{"spaced", "out"}, offsets: []int{2, 11}},
+		{line: `say "hello world"`, args: []string{"say", "hello world"}, offsets: []int{0, 4}},
+		{line: `say 'it is "fine"'`, args: []string{"say", `it is "fine"`}, offsets: []int{0, 4}},
+		{line: `say "it's fine"`, args: []string{"say", "it's fine"}, offsets: []int{0, 4}},
+		{line: `pre"quoted part"post`, args: []string{"prequoted partpost"}, offsets: []int{0}},
+		{line: `empty "" arg`, args: []string{"empty", "", "arg"}, offsets: []int{0, 6, 9}},
+		{line: `escaped\ space`, args: []string{"escaped space"}, offsets: []int{0}},
+		{line: `\"not quoted\"`, args: []string{`"not`, `quoted"`}, offsets: []int{0, 6}},
+		{line: "tab\tand\nnewline", args: []string{"tab", "and", "newline"}, offsets: []int{0, 4, 8}},
+		{line: "émoji 🎉 ok", args: []string{"émoji", "🎉", "ok"}, offsets: []int{0, 7, 12}},
+		{line: `say "unterminated`, err: true},
+		{line: `say 'unterminated`, err: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.line, func(t *testing.T) {
+			args, offsets, err := splitArgs(tt.line)
+			if tt.err {
+				if err == nil {
+					t.Fatalf("expected an error, got %q", args)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(args, tt.args) {
+				t.Errorf("args = %q, want %q", args, tt.args)
+			}
+			if !reflect.DeepEqual(offsets, tt.offsets) {
+				t.Errorf("offsets = %v, want %v", offsets, tt.offsets)
+			}
+		})
+	}
+}
+
+func TestParseArgs(t *testing.T) {
+	mentioned := types.NewJID("1112223333", types.DefaultUserServer)
+	msg := messages.NewIncomingMessage(&events.Message{
+		Message: &waProto.Message{
+			ExtendedTextMessage: &waProto.ExtendedTextMessage{
+				Text:        proto.String("!cmd"),
+				ContextInfo: &waProto.ContextInfo{MentionedJID: []string{mentioned.String()}},
+			},
+		},
+	})
+	positive := func(value interface{}) error {
+		if value.(int) <= 0 {
+			return errors.New("must be positive")
+		}
+		return nil
+	}
+
+	tests := []struct {
+		name string
+		args []Arg
+		body string
+		want map[string]interface{}
+		err  string
+	}{
+		{
+			name: "typed",
+			args: []Arg{{Name: "count", Type: ArgInt}, {Name: "for", Type: ArgDuration}, {Name: "who", Type: ArgJID}},
+			body: "3 1h30m +1 555 0100",
+			err:  "Too many arguments",
+		},
+		{
+			name: "typed with quoted jid",
+			args: []Arg{{Name: "count", Type: ArgInt}, {Name: "for", Type: ArgDuration}, {Name: "who", Type: ArgJID}},
+			body: `3 1h30m "+1 555 0100"`,
+			want: map[string]interface{}{"count": 3, "for": 90 * time.Minute, "who": types.NewJID("15550100", types.DefaultUserServer)},
+		},
+		{
+			name: "quoted string",
+			args: []Arg{{Name: "title", Type: ArgString}, {Name: "note", Type: ArgString}},
+			body: `"hello world" 'single quoted'`,
+			want: map[string]interface{}{"title": "hello world", "note": "single quoted"},
+		},
+		{
+			name: "rest keeps quotes and spacing",
+			args: []Arg{{Name: "who", Type: ArgString}, {Name: "reason", Type: ArgRest}},
+			body: `bob  said "hi"   twice `,
+			want: map[string]interface{}{"who": "bob", "reason": `said "hi"   twice`},
+		},
+		{
+			name: "rest after quoted argument",
+			args: []Arg{{Name: "title", Type: ArgString}, {Name: "body", Type: ArgRest}},
+			body: `"two words" then 'the rest'`,
+			want: map[string]interface{}{"title": "two words", "body": "then 'the rest'"},
+		},
+		{
+			name: "mention uses the attached JID",
+			args: []Arg{{Name: "user", Type: ArgMention}},
+			body: "@1112223333",
+			want: map[string]interface{}{"user": mentioned},
+		},
+		{
+			name: "mention without @",
+			args: []Arg{{Name: "user", Type: ArgMention}},
+			body: "1112223333",
+			err:  "Invalid user",
+		},
+		{
+			name: "optional missing",
+			args: []Arg{{Name: "name", Type: ArgString}, {Name: "count", Type: ArgInt, Optional: true}},
+			body: "x",
+			want: map[string]interface{}{"name": "x"},
+		},
+		{
+			name: "optional rest missing",
+			args: []Arg{{Name: "reason", Type: ArgRest, Optional: true}},
+			body: "",
+			want: map[string]interface{}{},
+		},
+		{
+			name: "required missing",
+			args: []Arg{{Name: "name", Type: ArgString}, {Name: "count", Type: ArgInt}},
+			body: "x",
+			err:  "Missing count",
+		},
+		{
+			name: "required rest missing",
+			args: []Arg{{Name: "reason", Type: ArgRest}},
+			body: "  ",
+			err:  "Missing reason",
+		},
+		{
+			name: "invalid int",
+			args: []Arg{{Name: "count", Type: ArgInt}},
+			body: "three",
+			err:  "Invalid count",
+		},
+		{
+			name: "validator",
+			args: []Arg{{Name: "count", Type: ArgInt, Validate: positive}},
+			body: "-2",
+			err:  "Invalid count: must be positive",
+		},
+		{
+			name: "too many",
+			args: []Arg{{Name: "name", Type: ArgString}},
+			body: `a "b c"`,
+			err:  "Too many arguments",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			words, offsets, err := splitArgs(tt.body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			values, err := parseArgs(&Command{Name: "cmd", Args: tt.args}, msg, words, offsets, tt.body)
+			if tt.err != "" {
+				var usage *UsageError
+				if !errors.As(err, &usage) || !strings.HasPrefix(usage.Message, tt.err) {
+					t.Fatalf("error = %v, want usage error starting with %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(values, tt.want) {
+				t.Errorf("values = %v, want %v", values, tt.want)
+			}
+		})
+	}
+}
+
+func TestRegisterSubCommands(t *testing.T) {
+	noop := func(*CommandContext) error { return nil }
+	tests := []struct {
+		name string
+		cmd  func() *Command
+		err  bool
+	}{
+		{
+			name: "distinct sub-commands",
+			cmd: func() *Command {
+				return (&Command{Name: "group"}).Sub(&Command{Name: "add", Handler: noop}).Sub(&Command{Name: "remove", Aliases: []string{"kick"}, Handler: noop})
+			},
+		},
+		{
+			name: "duplicate name",
+			cmd: func() *Command {
+				return (&Command{Name: "group"}).Sub(&Command{Name: "add", Handler: noop}).Sub(&Command{Name: "ADD", Handler: noop})
+			},
+			err: true,
+		},
+		{
+			name: "alias clashes with a name",
+			cmd: func() *Command {
+				return (&Command{Name: "group"}).Sub(&Command{Name: "add", Handler: noop}).Sub(&Command{Name: "invite", Aliases: []string{"add"}, Handler: noop})
+			},
+			err: true,
+		},
+		{
+			name: "nested duplicate",
+			cmd: func() *Command {
+				settings := (&Command{Name: "settings"}).Sub(&Command{Name: "name", Handler: noop}).Sub(&Command{Name: "name", Handler: noop})
+				return (&Command{Name: "group"}).Sub(settings)
+			},
+			err: true,
+		},
+		{
+			name: "sub-command without a name",
+			cmd:  func() *Command { return (&Command{Name: "group"}).Sub(&Command{Handler: noop}) },
+			err:  true,
+		},
+		{
+			name: "invalid sub-command arguments",
+			cmd: func() *Command {
+				return (&Command{Name: "group"}).Sub(&Command{Name: "add", Args: []Arg{{Name: "rest", Type: ArgRest}, {Name: "user"}}, Handler: noop})
+			},
+			err: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := NewRouter().Register(tt.cmd())
+			if tt.err && err == nil {
+				t.Error("expected an error")
+			}
+			if !tt.err && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestSubAfterRegister(t *testing.T) {
+	noop := func(*CommandContext) error { return nil }
+	router := NewRouter()
+	group := &Command{Name: "group"}
+	router.MustRegister(group)
+
+	// Adding sub-commands while the router is in use is safe
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			router.Help("!")
+		}
+	}()
+	for i := 0; i < 100; i++ {
+		group.Sub(&Command{Name: "sub" + strings.Repeat("x", i), Handler: noop})
+	}
+	<-done
+
+	if help := router.Help("!"); !strings.Contains(help, "!group subxx") {
+		t.Errorf("help doesn't list the new sub-commands:\n%s", help)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("adding a duplicate sub-command to a registered command didn't panic")
+		}
+	}()
+	group.Sub(&Command{Name: "sub", Handler: noop})
+}
@@ -0,0 +1,178 @@
+package whatsappclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	utils "github.com/hacxk/easy-meow/Utils"
+
+	"go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/types/events"
+)
+
+// ErrActiveDevice is returned when trying to remove the device the client is using
+var ErrActiveDevice = errors.New("device is in use by this client")
+
+// Logout unlinks the device on the server, disconnects and deletes the session from the store.
+// It needs a working connection; use ResetSession to drop the session locally when the server can't be reached.
+// whatsmeow's logout can't be cancelled, so if ctx ends first Logout returns ctx.Err() while the request
+// carries on in the background. If it then succeeds the client still disconnects and reports StateLoggedOut.
+func (wac *WhatsAppClient) Logout(ctx context.Context) error {
+	if wac.client.Store.ID == nil {
+		return ErrNotLoggedIn
+	}
+
+	_, err := utils.AwaitContext(ctx, func() (struct{}, error) {
+		err := wac.client.Logout()
+		// Runs even when the caller stopped waiting, so a logout that went through always disconnects
+		if err == nil {
+			wac.conn.setState(StateChange{State: StateLoggedOut})
+			wac.Disconnect()
+		}
+		return struct{}{}, err
+	})
+	if err != nil {
+		if ctx.Err() != nil {
+			return err
+		}
+		return fmt.Errorf("failed to log out: %w", err)
+	}
+	return nil
+}
+
+// ResetSession disconnects and deletes the session from the store without telling the server.
+// The phone keeps listing the device until it is removed there. The next Connect links a new device.
+func (wac *WhatsAppClient) ResetSession() error {
+	wac.Disconnect()
+	if wac.client.Store.ID != nil {
+		if err := wac.client.Store.Delete(); err != nil {
+			return fmt.Errorf("failed to delete device: %w", err)
+		}
+	}
+	return nil
+}
+
+// handleLoggedOut cleans up after the device was unlinked from the phone or by WhatsApp and, unless
+// disabled in the login options, starts linking again. Devices that keep getting unlinked wait longer
+// before each new login, following the reconnect backoff.
+func (wac *WhatsAppClient) handleLoggedOut(evt *events.LoggedOut) {
+	// The old connection must be gone before a new device can connect
+	wac.client.Disconnect()
+
+	// whatsmeow usually deletes the session itself, but not if that failed
+	if wac.client.Store.ID != nil {
+		if err := wac.client.Store.Delete(); err != nil {
+			wac.client.Log.Warnf("Failed to delete device after logout: %v", err)
+		}
+	}
+
+	if wac.loginOptions.NoRelinkOnLogout || evt.Reason == events.ConnectFailureUnknownLogout {
+		return
+	}
+	attempt, ok := wac.relink.begin()
+	if !ok {
+		return
+	}
+	defer wac.relink.end()
+
+	delay := wac.conn.backoff(attempt)
+	wac.client.Log.Infof("Device was logged out (%s), starting a new login in %v", evt.Reason, delay)
+	select {
+	case <-wac.conn.stopping():
+		return
+	case <-wac.handlerCtx.Done():
+		return
+	case <-time.After(delay):
+	}
+	if err := wac.Connect(wac.handlerCtx); err != nil {
+		wac.client.Log.Errorf("Failed to log in again: %v", err)
+	}
+}
+
+// relinkTracker counts logouts in a row so the client doesn't relink in a loop
+type relinkTracker struct {
+	mu         sync.Mutex
+	running    bool
+	attempts   int
+	lastLogout time.Time
+}
+
+// relinkResetAfter is how long a device has to stay linked before the relink backoff starts over
+const relinkResetAfter = 30 * time.Minute
+
+// begin returns the number of this relink, starting at 1, or false if one is already running
+func (r *relinkTracker) begin() (int, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.running {
+		return 0, false
+	}
+	if time.Since(r.lastLogout) > relinkResetAfter {
+		r.attempts = 0
+	}
+	r.running = true
+	r.attempts++
+	r.lastLogout = time.Now()
+	return r.attempts, true
+}
+
+func (r *relinkTracker) end() {
+	r.mu.Lock()
+	r.running = false
+	r.mu.Unlock()
+}
+
+// StoredDevices returns the JIDs of every device in the session store, including ones no client uses
+func (wac *WhatsAppClient) StoredDevices() ([]types.JID, error) {
+	devices, err := wac.container.GetAllDevices()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get devices from store: %w", err)
+	}
+	jids := make([]types.JID, 0, len(devices))
+	for _, device := range devices {
+		jids = append(jids, *device.ID)
+	}
+	return jids, nil
+}
+
+// RemoveStoredDevice deletes a device from the session store without contacting the server,
+// e.g. one left behind after it was unlinked from the phone while offline
+func (wac *WhatsAppClient) RemoveStoredDevice(jid types.JID) error {
+	if id := wac.client.Store.ID; id != nil && *id == jid {
+		return ErrActiveDevice
+	}
+	device, err := wac.container.GetDevice(jid)
+	if err != nil {
+		return fmt.Errorf("failed to get device from store: %w", err)
+	}
+	if device == nil {
+		return nil
+	}
+	if err := wac.container.DeleteDevice(device); err != nil {
+		return fmt.Errorf("failed to delete device: %w", err)
+	}
+	return nil
+}
+
+// PruneStoredDevices deletes every device except the one this client uses and returns what was removed.
+// Don't use it on a store shared by a Manager, where the other devices are live accounts.
+func (wac *WhatsAppClient) PruneStoredDevices() ([]types.JID, error) {
+	jids, err := wac.StoredDevices()
+	if err != nil {
+		return nil, err
+	}
+	var removed []types.JID
+	for _, jid := range jids {
+		err := wac.RemoveStoredDevice(jid)
+		if errors.Is(err, ErrActiveDevice) {
+			continue
+		} else if err != nil {
+			return removed, err
+		}
+		removed = append(removed, jid)
+	}
+	return removed, nil
+}
@@ -0,0 +1,46 @@
+package whatsappclient
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"go.mau.fi/whatsmeow"
+	"go.mau.fi/whatsmeow/types"
+)
+
+func TestLogout(t *testing.T) {
+	tests := []struct {
+		name     string
+		loggedIn bool
+		err      error
+	}{
+		{name: "not logged in", err: ErrNotLoggedIn},
+		{name: "not connected", loggedIn: true, err: whatsmeow.ErrNotConnected},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			wac, err := NewWhatsAppClient(filepath.Join(t.TempDir(), "session.db"), WithLogLevel("ERROR"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.loggedIn {
+				id := types.NewJID("1234567890", types.DefaultUserServer)
+				wac.client.Store.ID = &id
+			}
+
+			if err := wac.Logout(context.Background()); !errors.Is(err, tt.err) {
+				t.Fatalf("error = %v, want %v", err, tt.err)
+			}
+			// A failed logout keeps the session and doesn't report being logged out
+			if tt.loggedIn && wac.client.Store.ID == nil {
+				t.Error("session was dropped")
+			}
+			if state := wac.State(); state == StateLoggedOut {
+				t.Errorf("state = %s after a failed logout", state)
+			}
+		})
+	}
+}
@@ -0,0 +1,608 @@
+package messages
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"math"
+	"strings"
+	"time"
+
+	utils "github.com/hacxk/easy-meow/Utils"
+
+	"go.mau.fi/whatsmeow"
+	waProto "go.mau.fi/whatsmeow/binary/proto"
+	"go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/types/events"
+	"google.golang.org/protobuf/proto"
+)
+
+// Uploader is the part of *whatsmeow.Client the builder needs to upload media.
+// Tests can pass a fake to build messages without a connection.
+type Uploader interface {
+	Upload(ctx context.Context, plaintext []byte, appInfo whatsmeow.MediaType) (whatsmeow.UploadResponse, error)
+}
+
+type messageKind int
+
+const (
+	kindText messageKind = iota
+	kindImage
+	kindVideo
+	kindAudio
+	kindDocument
+	kindSticker
+	kindGif
+	kindContact
+	kindLocation
+	kindPoll
+)
+
+// MessageBuilder composes a message step by step and turns it into a *waProto.Message without sending it.
+//
+//	msg, err := messages.NewImage(utils.FromPath("cat.jpg")).Caption("meow").ReplyTo(evt).ViewOnce().Build(ctx, client)
+type MessageBuilder struct {
+	kind     messageKind
+	text     string
+	source   utils.MediaSource
+	caption  string
+	fileName string
+	ptt      bool
+	viewOnce bool
+	mentions []string
+	replyTo  *events.Message
+	contacts []*Contact
+	location *Location
+	preview  *LinkPreview
+	fetcher  *PreviewFetcher
+	poll     *pollOptions
+	sticker  utils.StickerMetadata
+	video    *utils.VideoOptions
+	changes  []string
+	err      error
+
+	// expiration is the disappearing messages timer in seconds; expirationSet tells an explicit zero from none
+	expiration    uint32
+	expirationSet bool
+	// participant is the user a text is attributed to without quoting anything, see NewPhoneNumberText
+	participant string
+}
+
+// NewText starts a text message
+func NewText(text string) *MessageBuilder {
+	return &MessageBuilder{kind: kindText, text: text}
+}
+
+// NewPhoneNumberText starts a text that names phoneNumber as its participant, as SendPhoneNumberMessageTo sends it
+//
+// Deprecated: recipients don't get a tappable contact. Use NewContacts(NewContact(name).AddPhone(number)).
+func NewPhoneNumberText(phoneNumber, text string) *MessageBuilder {
+	return &MessageBuilder{kind: kindText, text: text, participant: phoneNumber + "@s.whatsapp.net"}
+}
+
+// NewImage starts an image message
+func NewImage(source utils.MediaSource) *MessageBuilder {
+	return &MessageBuilder{kind: kindImage, source: source}
+}
+
+// NewVideo starts a video message. The video is probed for its duration and size and converted
+// to an MP4 WhatsApp accepts according to the VideoOptions.
+func NewVideo(source utils.MediaSource) *MessageBuilder {
+	return &MessageBuilder{kind: kindVideo, source: source}
+}
+
+// NewAudio starts an audio message. Call PTT to send it as a voice note.
+func NewAudio(source utils.MediaSource) *MessageBuilder {
+	return &MessageBuilder{kind: kindAudio, source: source}
+}
+
+// NewAudioPTT starts an audio message that is a voice note when ptt is set
+func NewAudioPTT(source utils.MediaSource, ptt bool) *MessageBuilder {
+	builder := NewAudio(source)
+	if ptt {
+		builder.PTT()
+	}
+	return builder
+}
+
+// NewDocument starts a document message
+func NewDocument(source utils.MediaSource) *MessageBuilder {
+	return &MessageBuilder{kind: kindDocument, source: source}
+}
+
+// NewSticker starts a sticker message
+func NewSticker(source utils.MediaSource) *MessageBuilder {
+	return &MessageBuilder{kind: kindSticker, source: source}
+}
+
+// NewGif starts an auto-playing, looping video message. GIF and animated WebP input is converted to MP4.
+func NewGif(source utils.MediaSource) *MessageBuilder {
+	return &MessageBuilder{kind: kindGif, source: source}
+}
+
+// pollOptions are the question and answers of a poll
+type pollOptions struct {
+	question   string
+	options    []string
+	selectable uint32
+}
+
+// NewPoll starts a poll. With onlyOnce set voters can pick a single answer, otherwise any number of them.
+func NewPoll(question string, options []string, onlyOnce bool) *MessageBuilder {
+	return &MessageBuilder{kind: kindPoll, poll: &pollOptions{question: question, options: options, selectable: uint32(btoi(onlyOnce))}}
+}
+
+// Caption sets the caption of media messages. For text messages it replaces the text.
+func (b *MessageBuilder) Caption(caption string) *MessageBuilder {
+	if b.kind == kindText {
+		b.text = caption
+	} else {
+		b.caption = caption
+	}
+	return b
+}
+
+// FileName sets the file name shown for documents. It defaults to the name of the source.
+func (b *MessageBuilder) FileName(fileName string) *MessageBuilder {
+	b.fileName = fileName
+	return b
+}
+
+// PTT marks an audio message as a push-to-talk voice note. The audio is transcoded to OGG/Opus unless it
+// already is, and gets a duration and waveform, so any input format plays like a recorded voice note.
+// Without ffmpeg installed the audio is sent as it is.
+func (b *MessageBuilder) PTT() *MessageBuilder {
+	b.ptt = true
+	return b
+}
+
+// ReplyTo quotes the given incoming message
+func (b *MessageBuilder) ReplyTo(evt *events.Message) *MessageBuilder {
+	b.replyTo = evt
+	return b
+}
+
+// Mention tags users by JID or phone number. Invalid entries are reported by Build.
+func (b *MessageBuilder) Mention(users ...string) *MessageBuilder {
+	for _, user := range users {
+		jid, err := ParseRecipient(user)
+		if err != nil {
+			if b.err == nil {
+				b.err = fmt.Errorf("invalid mentioned JID: %w", err)
+			}
+			continue
+		}
+		b.mentions = append(b.mentions, jid.String())
+	}
+	return b
+}
+
+// StickerPack sets the pack name, publisher and emojis embedded in a sticker
+func (b *MessageBuilder) StickerPack(metadata utils.StickerMetadata) *MessageBuilder {
+	b.sticker = metadata
+	return b
+}
+
+// VideoOptions overrides utils.DefaultVideoOptions for how a video is normalized and compressed
+func (b *MessageBuilder) VideoOptions(opts utils.VideoOptions) *MessageBuilder {
+	b.video = &opts
+	return b
+}
+
+// Changes lists what the last Build did to the media to make it sendable, such as transcoding or compressing a video
+func (b *MessageBuilder) Changes() []string {
+	return b.changes
+}
+
+// ViewOnce makes image, video and voice (PTT) messages disappear after the recipient opens them
+func (b *MessageBuilder) ViewOnce() *MessageBuilder {
+	b.viewOnce = true
+	return b
+}
+
+// Expiration makes the message disappear after the given time, which should match the chat's
+// disappearing messages timer. Zero sends a message that stays.
+func (b *MessageBuilder) Expiration(timer time.Duration) *MessageBuilder {
+	b.expiration = uint32(timer / time.Second)
+	b.expirationSet = true
+	return b
+}
+
+// InheritExpiration applies a chat's disappearing messages timer unless Expiration was called
+func (b *MessageBuilder) InheritExpiration(timer time.Duration) *MessageBuilder {
+	if !b.expirationSet {
+		b.expiration = uint32(timer / time.Second)
+	}
+	return b
+}
+
+// Preview attaches a link preview to a text message, for links whose page can't be fetched or to control what is shown
+func (b *MessageBuilder) Preview(preview LinkPreview) *MessageBuilder {
+	b.preview = &preview
+	return b
+}
+
+// AutoPreview fetches a preview for the first link in a text message when it is built, using
+// DefaultPreviewFetcher unless another fetcher is given. Links whose page can't be fetched are sent without one.
+func (b *MessageBuilder) AutoPreview(fetcher ...*PreviewFetcher) *MessageBuilder {
+	b.fetcher = DefaultPreviewFetcher
+	if len(fetcher) > 0 && fetcher[0] != nil {
+		b.fetcher = fetcher[0]
+	}
+	return b
+}
+
+// Build uploads any media through up and returns the finished message
+func (b *MessageBuilder) Build(ctx context.Context, up Uploader) (*waProto.Message, error) {
+	if b.err != nil {
+		return nil, b.err
+	}
+	if b.viewOnce && b.kind != kindImage && b.kind != kindVideo && !(b.kind == kindAudio && b.ptt) {
+		return nil, fmt.Errorf("view once is only supported for image, video and voice messages")
+	}
+	if (b.preview != nil || b.fetcher != nil) && b.kind != kindText {
+		return nil, fmt.Errorf("link previews are only supported for text messages")
+	}
+
+	contextInfo := b.contextInfo()
+
+	if b.kind == kindText {
+		preview, err := b.linkPreview(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if contextInfo == nil && preview == nil {
+			return &waProto.Message{Conversation: proto.String(b.text)}, nil
+		}
+		text := &waProto.ExtendedTextMessage{
+			Text:        proto.String(b.text),
+			ContextInfo: contextInfo,
+		}
+		if preview != nil {
+			text.MatchedText = proto.String(preview.MatchedText)
+			text.CanonicalURL = proto.String(preview.CanonicalURL)
+			text.Title = proto.String(preview.Title)
+			text.Description = proto.String(preview.Description)
+			text.JPEGThumbnail = preview.Thumbnail
+			text.PreviewType = waProto.ExtendedTextMessage_NONE.Enum()
+		}
+		return &waProto.Message{ExtendedTextMessage: text}, nil
+	}
+
+	if b.kind == kindContact {
+		return b.buildContacts(contextInfo)
+	}
+	if b.kind == kindLocation {
+		return b.buildLocation(ctx, contextInfo)
+	}
+	if b.kind == kindPoll {
+		options := make([]*waProto.PollCreationMessage_Option, len(b.poll.options))
+		for i, option := range b.poll.options {
+			options[i] = &waProto.PollCreationMessage_Option{OptionName: proto.String(option)}
+		}
+		return &waProto.Message{
+			PollCreationMessage: &waProto.PollCreationMessage{
+				Name:                   proto.String(b.poll.question),
+				Options:                options,
+				SelectableOptionsCount: proto.Uint32(b.poll.selectable),
+				ContextInfo:            contextInfo,
+			},
+		}, nil
+	}
+
+	if b.source == nil {
+		return nil, fmt.Errorf("no media source given")
+	}
+	media, err := b.source.Open(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", b.kind, err)
+	}
+
+	// Convert the media into something WhatsApp will render before uploading it
+	var sticker *utils.Sticker
+	if b.kind == kindSticker {
+		sticker, err = utils.ConvertToSticker(ctx, media, b.sticker)
+		if err != nil {
+			return nil, err
+		}
+		media = &utils.Media{Data: sticker.Data, MimeType: "image/webp", FileName: media.FileName}
+	}
+
+	b.changes = nil
+	var seconds, width, height uint32
+	var waveform []byte
+	if b.kind == kindVideo {
+		opts := utils.DefaultVideoOptions
+		if b.video != nil {
+			opts = *b.video
+		}
+		prepared, err := utils.PrepareVideo(ctx, media, opts)
+		if err != nil && !utils.IsToolMissing(err) {
+			return nil, err
+		}
+		// Without ffmpeg installed the video is uploaded untouched
+		if err == nil {
+			media = &utils.Media{Data: prepared.Data, MimeType: prepared.MimeType, FileName: media.FileName}
+			width, height, seconds = uint32(prepared.Width), uint32(prepared.Height), prepared.Seconds
+			b.changes = prepared.Changes
+		}
+	}
+	if b.kind == kindGif {
+		if media.MimeType == "video/mp4" {
+			if info, err := utils.ProbeMedia(ctx, media); err == nil {
+				width, height = uint32(info.Width), uint32(info.Height)
+				seconds = uint32(math.Round(info.Duration))
+			}
+		} else {
+			// A real GIF uploaded as video/mp4 doesn't play, so it is converted first
+			video, err := utils.ConvertToGifVideo(ctx, media)
+			if err != nil {
+				return nil, err
+			}
+			media = &utils.Media{Data: video.Data, MimeType: "video/mp4", FileName: media.FileName}
+			width, height, seconds = uint32(video.Width), uint32(video.Height), video.Seconds
+		}
+	}
+	if b.kind == kindAudio {
+		if b.ptt {
+			// Voice notes only render as such when they are OGG/Opus with a duration and waveform
+			voice, err := utils.ConvertToVoiceNote(ctx, media)
+			if err != nil && !utils.IsToolMissing(err) {
+				return nil, err
+			}
+			// Without ffmpeg installed the audio is uploaded untouched
+			if err == nil {
+				media = &utils.Media{Data: voice.Data, MimeType: utils.VoiceNoteMimeType, FileName: media.FileName}
+				seconds, waveform = voice.Seconds, voice.Waveform
+			}
+		} else if duration, err := utils.AudioDuration(ctx, media); err == nil {
+			seconds = duration
+		}
+	}
+
+	uploaded, err := up.Upload(ctx, media.Data, b.kind.mediaType())
+	if err != nil {
+		return nil, fmt.Errorf("failed to upload %s: %w", b.kind, err)
+	}
+
+	msg := &waProto.Message{}
+	switch b.kind {
+	case kindImage:
+		msg.ImageMessage = &waProto.ImageMessage{
+			URL:           proto.String(uploaded.URL),
+			DirectPath:    proto.String(uploaded.DirectPath),
+			MediaKey:      uploaded.MediaKey,
+			FileEncSHA256: uploaded.FileEncSHA256,
+			FileSHA256:    uploaded.FileSHA256,
+			FileLength:    proto.Uint64(uploaded.FileLength),
+			Mimetype:      proto.String(media.MimeType),
+			ContextInfo:   contextInfo,
+		}
+		if b.caption != "" {
+			msg.ImageMessage.Caption = proto.String(b.caption)
+		}
+		if config, _, err := image.DecodeConfig(bytes.NewReader(media.Data)); err == nil {
+			msg.ImageMessage.Width = proto.Uint32(uint32(config.Width))
+			msg.ImageMessage.Height = proto.Uint32(uint32(config.Height))
+		}
+		// Get and Set Thumbnail (With Error Handling)
+		if thumbnailBytes, err := utils.GetMediaThumbnail(ctx, media); err == nil {
+			msg.ImageMessage.JPEGThumbnail = thumbnailBytes
+		}
+		if b.viewOnce {
+			msg.ImageMessage.ViewOnce = proto.Bool(true)
+			msg = &waProto.Message{ViewOnceMessage: &waProto.FutureProofMessage{Message: msg}}
+		}
+
+	case kindVideo, kindGif:
+		msg.VideoMessage = &waProto.VideoMessage{
+			URL:           proto.String(uploaded.URL),
+			DirectPath:    proto.String(uploaded.DirectPath),
+			MediaKey:      uploaded.MediaKey,
+			FileEncSHA256: uploaded.FileEncSHA256,
+			FileSHA256:    uploaded.FileSHA256,
+			FileLength:    proto.Uint64(uploaded.FileLength),
+			Mimetype:      proto.String(media.MimeType),
+			ContextInfo:   contextInfo,
+		}
+		if b.kind == kindGif {
+			msg.VideoMessage.GifPlayback = proto.Bool(true)
+		}
+		if seconds > 0 {
+			msg.VideoMessage.Seconds = proto.Uint32(seconds)
+		}
+		if width > 0 && height > 0 {
+			msg.VideoMessage.Width = proto.Uint32(width)
+			msg.VideoMessage.Height = proto.Uint32(height)
+		}
+		if b.caption != "" {
+			msg.VideoMessage.Caption = proto.String(b.caption)
+		}
+		// Get and Set Thumbnail (With Error Handling)
+		if thumbnailBytes, err := utils.GetMediaThumbnail(ctx, media); err == nil {
+			msg.VideoMessage.JPEGThumbnail = thumbnailBytes
+		}
+		if b.viewOnce {
+			msg.VideoMessage.ViewOnce = proto.Bool(true)
+			msg = &waProto.Message{ViewOnceMessage: &waProto.FutureProofMessage{Message: msg}}
+		}
+
+	case kindAudio:
+		msg.AudioMessage = &waProto.AudioMessage{
+			URL:           proto.String(uploaded.URL),
+			DirectPath:    proto.String(uploaded.DirectPath),
+			MediaKey:      uploaded.MediaKey,
+			FileEncSHA256: uploaded.FileEncSHA256,
+			FileSHA256:    uploaded.FileSHA256,
+			FileLength:    proto.Uint64(uploaded.FileLength),
+			Mimetype:      proto.String(media.MimeType),
+			PTT:           proto.Bool(b.ptt),
+			ContextInfo:   contextInfo,
+		}
+		if seconds > 0 {
+			msg.AudioMessage.Seconds = proto.Uint32(seconds)
+		}
+		if len(waveform) > 0 {
+			msg.AudioMessage.Waveform = waveform
+		}
+		if b.viewOnce {
+			// Voice notes use the newer container; clients don't render them as view-once in the old one
+			msg.AudioMessage.ViewOnce = proto.Bool(true)
+			msg = &waProto.Message{ViewOnceMessageV2Extension: &waProto.FutureProofMessage{Message: msg}}
+		}
+
+	case kindDocument:
+		fileName := b.fileName
+		if fileName == "" {
+			fileName = media.FileName // Fall back to the name the source was loaded from
+		}
+		msg.DocumentMessage = &waProto.DocumentMessage{
+			URL:           proto.String(uploaded.URL),
+			DirectPath:    proto.String(uploaded.DirectPath),
+			MediaKey:      uploaded.MediaKey,
+			FileEncSHA256: uploaded.FileEncSHA256,
+			FileSHA256:    uploaded.FileSHA256,
+			FileLength:    proto.Uint64(uploaded.FileLength),
+			Mimetype:      proto.String(media.MimeType),
+			FileName:      proto.String(fileName),
+			ContextInfo:   contextInfo,
+		}
+		if b.caption != "" {
+			msg.DocumentMessage.Caption = proto.String(b.caption)
+		}
+
+	case kindSticker:
+		msg.StickerMessage = &waProto.StickerMessage{
+			URL:           proto.String(uploaded.URL),
+			DirectPath:    proto.String(uploaded.DirectPath),
+			MediaKey:      uploaded.MediaKey,
+			FileEncSHA256: uploaded.FileEncSHA256,
+			FileSHA256:    uploaded.FileSHA256,
+			FileLength:    proto.Uint64(uploaded.FileLength),
+			Mimetype:      proto.String("image/webp"),
+			Width:         proto.Uint32(uint32(sticker.Width)),
+			Height:        proto.Uint32(uint32(sticker.Height)),
+			IsAnimated:    proto.Bool(sticker.Animated),
+			ContextInfo:   contextInfo,
+		}
+	}
+
+	return msg, nil
+}
+
+// linkPreview returns the preview set with Preview or fetched for AutoPreview, or nil when the text gets none.
+// A preview set with Preview for a text without a link is an error, since WhatsApp has nothing to attach it to.
+func (b *MessageBuilder) linkPreview(ctx context.Context) (*LinkPreview, error) {
+	if b.preview != nil {
+		preview := *b.preview
+		if preview.MatchedText == "" {
+			preview.MatchedText = FirstURL(b.text)
+		}
+		if preview.MatchedText == "" {
+			return nil, fmt.Errorf("link preview needs a link in the text or a MatchedText")
+		}
+		if preview.CanonicalURL == "" {
+			preview.CanonicalURL = preview.MatchedText
+			if !strings.Contains(preview.CanonicalURL, "://") {
+				preview.CanonicalURL = "https://" + preview.CanonicalURL
+			}
+		}
+		return &preview, nil
+	}
+	if b.fetcher == nil {
+		return nil, nil
+	}
+	link := FirstURL(b.text)
+	if link == "" {
+		return nil, nil
+	}
+	// Pages that can't be fetched are sent without a preview
+	preview, err := b.fetcher.Fetch(ctx, link)
+	if err != nil {
+		return nil, nil
+	}
+	return preview, nil
+}
+
+// contextInfo collects the reply, mention and expiration data, or returns nil when there is none
+func (b *MessageBuilder) contextInfo() *waProto.ContextInfo {
+	if b.replyTo == nil && b.participant == "" && len(b.mentions) == 0 && b.expiration == 0 {
+		return nil
+	}
+
+	contextInfo := &waProto.ContextInfo{}
+	if b.participant != "" {
+		contextInfo.Participant = proto.String(b.participant)
+	}
+	if b.replyTo != nil {
+		contextInfo.StanzaID = proto.String(b.replyTo.Info.ID)
+		// The quoted participant is the sender without any device part
+		contextInfo.Participant = proto.String(b.replyTo.Info.Sender.ToNonAD().String())
+		contextInfo.QuotedMessage = b.replyTo.Message
+	}
+	if len(b.mentions) > 0 {
+		contextInfo.MentionedJID = b.mentions
+	}
+	if b.expiration > 0 {
+		contextInfo.Expiration = proto.Uint32(b.expiration)
+	}
+	return contextInfo
+}
+
+// SendBuilt builds the message with the client as uploader and sends it to the given chat or user
+func SendBuilt(ctx context.Context, client *whatsmeow.Client, to types.JID, builder *MessageBuilder) (*whatsmeow.SendResponse, error) {
+	msg, err := builder.Build(ctx, client)
+	if err != nil {
+		return nil, err
+	}
+
+	sendResp, err := client.SendMessage(ctx, to, msg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send message: %w", err)
+	}
+	return &sendResp, nil
+}
+
+func (k messageKind) mediaType() whatsmeow.MediaType {
+	switch k {
+	case kindVideo, kindGif:
+		return whatsmeow.MediaVideo
+	case kindAudio:
+		return whatsmeow.MediaAudio
+	case kindDocument:
+		return whatsmeow.MediaDocument
+	default:
+		return whatsmeow.MediaImage
+	}
+}
+
+func (k messageKind) String() string {
+	switch k {
+	case kindText:
+		return "text"
+	case kindImage:
+		return "image"
+	case kindVideo:
+		return "video"
+	case kindAudio:
+		return "audio"
+	case kindDocument:
+		return "document"
+	case kindSticker:
+		return "sticker"
+	case kindGif:
+		return "GIF"
+	case kindContact:
+		return "contact"
+	case kindLocation:
+		return "location"
+	case kindPoll:
+		return "poll"
+	default:
+		return "message"
+	}
+}
@@ -0,0 +1,495 @@
+package messages
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	utils "github.com/hacxk/easy-meow/Utils"
+
+	"go.mau.fi/whatsmeow"
+	waProto "go.mau.fi/whatsmeow/binary/proto"
+	"go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/types/events"
+)
+
+// fakeUploader records uploads instead of sending them to WhatsApp
+type fakeUploader struct {
+	types []whatsmeow.MediaType
+}
+
+func (f *fakeUploader) Upload(ctx context.Context, plaintext []byte, appInfo whatsmeow.MediaType) (whatsmeow.UploadResponse, error) {
+	f.types = append(f.types, appInfo)
+	return whatsmeow.UploadResponse{
+		URL:           "https://mmg.whatsapp.net/test",
+		DirectPath:    "/test",
+		MediaKey:      []byte("key"),
+		FileEncSHA256: []byte("enc"),
+		FileSHA256:    []byte("sha"),
+		FileLength:    uint64(len(plaintext)),
+	}, nil
+}
+
+func testPNG(t *testing.T, width, height int) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for i := range img.Pix {
+		img.Pix[i] = 0xff
+	}
+	img.Set(0, 0, color.Black)
+	buf := new(bytes.Buffer)
+	if err := png.Encode(buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// testWebP is a 512x512 lossless WebP header, which stickers accept as-is without ffmpeg
+func testWebP() []byte {
+	bits := uint32(511) | uint32(511)<<14
+	payload := []byte{0x2f, byte(bits), byte(bits >> 8), byte(bits >> 16), byte(bits >> 24), 0}
+	return writeTestWebP("VP8L", payload)
+}
+
+func writeTestWebP(fourCC string, payload []byte) []byte {
+	chunk := append([]byte(fourCC), byte(len(payload)), byte(len(payload)>>8), 0, 0)
+	chunk = append(chunk, payload...)
+	if len(payload)%2 == 1 {
+		chunk = append(chunk, 0)
+	}
+	size := 4 + len(chunk)
+	out := append([]byte("RIFF"), byte(size), byte(size>>8), 0, 0)
+	out = append(out, "WEBP"...)
+	return append(out, chunk...)
+}
+
+// ffmpegOutput renders a test clip with ffmpeg, or returns nil when it isn't installed
+func ffmpegOutput(t *testing.T, name string, args ...string) []byte {
+	t.Helper()
+	if !hasFFmpeg() {
+		return nil
+	}
+	out := filepath.Join(t.TempDir(), name)
+	cmd := exec.Command("ffmpeg", append(append([]string{"-y", "-loglevel", "error"}, args...), out)...)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("ffmpeg failed: %v\n%s", err, output)
+	}
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+// testVideo is a real MP4 when ffmpeg is installed. Without it the builder uploads videos untouched,
+// so the start of an MP4 file is enough.
+func testVideo(t *testing.T) []byte {
+	if video := ffmpegOutput(t, "clip.mp4", "-f", "lavfi", "-i", "testsrc=size=64x64:rate=10", "-t", "1",
+		"-pix_fmt", "yuv420p", "-c:v", "libx264", "-movflags", "+faststart"); video != nil {
+		return video
+	}
+	return []byte("\x00\x00\x00\x18ftypmp42\x00\x00\x00\x00mp42isom")
+}
+
+// testVoice is a WAV file when ffmpeg is installed. Without it voice notes are uploaded untouched,
+// so the start of an OGG file is enough.
+func testVoice(t *testing.T) []byte {
+	if voice := ffmpegOutput(t, "voice.wav", "-f", "lavfi", "-i", "sine=duration=1"); voice != nil {
+		return voice
+	}
+	return []byte("OggS\x00\x02\x00\x00\x00\x00\x00\x00\x00\x00")
+}
+
+func hasFFmpeg() bool {
+	_, err := exec.LookPath("ffmpeg")
+	return err == nil
+}
+
+func testReply() *events.Message {
+	return &events.Message{
+		Info: types.MessageInfo{
+			ID: "3EB0C0FFEE",
+			MessageSource: types.MessageSource{
+				Chat:   types.NewJID("1234567890", types.DefaultUserServer),
+				Sender: types.NewADJID("1234567890", 0, 3),
+			},
+		},
+		Message: &waProto.Message{Conversation: strPtr("hello")},
+	}
+}
+
+func strPtr(s string) *string {
+	return &s
+}
+
+func TestBuildText(t *testing.T) {
+	up := &fakeUploader{}
+	msg, err := NewText("hi").Build(context.Background(), up)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.GetConversation() != "hi" || msg.ExtendedTextMessage != nil {
+		t.Errorf("plain text should be a conversation message, got %v", msg)
+	}
+	if len(up.types) != 0 {
+		t.Errorf("text uploaded %d files", len(up.types))
+	}
+}
+
+func TestBuildTextMentionReply(t *testing.T) {
+	reply := testReply()
+	msg, err := NewText("hi @1112223333").Mention("1112223333").ReplyTo(reply).Build(context.Background(), &fakeUploader{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	text := msg.GetExtendedTextMessage()
+	if text.GetText() != "hi @1112223333" {
+		t.Errorf("text = %q", text.GetText())
+	}
+	info := text.GetContextInfo()
+	if got := info.GetMentionedJID(); len(got) != 1 || got[0] != "1112223333@s.whatsapp.net" {
+		t.Errorf("mentions = %v", got)
+	}
+	if info.GetStanzaID() != reply.Info.ID {
+		t.Errorf("stanza ID = %q", info.GetStanzaID())
+	}
+	if info.GetParticipant() != "1234567890@s.whatsapp.net" {
+		t.Errorf("participant = %q, want the sender without device", info.GetParticipant())
+	}
+	if info.GetQuotedMessage().GetConversation() != "hello" {
+		t.Errorf("quoted message = %v", info.GetQuotedMessage())
+	}
+}
+
+func TestBuildInvalidMention(t *testing.T) {
+	if _, err := NewText("hi").Mention("not a number").Build(context.Background(), &fakeUploader{}); err == nil {
+		t.Error("expected an error for an invalid mention")
+	}
+}
+
+func TestBuildMedia(t *testing.T) {
+	tests := []struct {
+		name    string
+		builder func(t *testing.T) *MessageBuilder
+		upload  whatsmeow.MediaType
+		check   func(t *testing.T, msg *waProto.Message)
+	}{
+		{
+			name: "image",
+			builder: func(t *testing.T) *MessageBuilder {
+				return NewImage(utils.FromBytes(testPNG(t, 40, 30), "photo.png")).Caption("look")
+			},
+			upload: whatsmeow.MediaImage,
+			check: func(t *testing.T, msg *waProto.Message) {
+				img := msg.GetImageMessage()
+				if img.GetMimetype() != "image/png" || img.GetCaption() != "look" {
+					t.Errorf("mimetype %q, caption %q", img.GetMimetype(), img.GetCaption())
+				}
+				if img.GetWidth() != 40 || img.GetHeight() != 30 {
+					t.Errorf("size %dx%d", img.GetWidth(), img.GetHeight())
+				}
+				if img.GetURL() == "" || img.GetDirectPath() != "/test" || img.GetFileLength() == 0 {
+					t.Errorf("upload fields missing: %v", img)
+				}
+			},
+		},
+		{
+			name: "video",
+			builder: func(t *testing.T) *MessageBuilder {
+				return NewVideo(utils.FromBytes(testVideo(t), "clip.mp4")).Caption("watch")
+			},
+			upload: whatsmeow.MediaVideo,
+			check: func(t *testing.T, msg *waProto.Message) {
+				video := msg.GetVideoMessage()
+				if video.GetMimetype() != "video/mp4" || video.GetCaption() != "watch" || video.GetGifPlayback() {
+					t.Errorf("unexpected video message %v", video)
+				}
+			},
+		},
+		{
+			name: "gif",
+			builder: func(t *testing.T) *MessageBuilder {
+				return NewGif(utils.FromBytes(testVideo(t), "loop.mp4"))
+			},
+			upload: whatsmeow.MediaVideo,
+			check: func(t *testing.T, msg *waProto.Message) {
+				if !msg.GetVideoMessage().GetGifPlayback() {
+					t.Error("gif should have GIF playback set")
+				}
+			},
+		},
+		{
+			name: "audio",
+			builder: func(t *testing.T) *MessageBuilder {
+				return NewAudio(utils.FromBytes([]byte("ID3\x03\x00\x00\x00\x00\x00\x00"), "song.mp3"))
+			},
+			upload: whatsmeow.MediaAudio,
+			check: func(t *testing.T, msg *waProto.Message) {
+				audio := msg.GetAudioMessage()
+				if audio.GetMimetype() != "audio/mpeg" || audio.GetPTT() {
+					t.Errorf("unexpected audio message %v", audio)
+				}
+			},
+		},
+		{
+			name: "voice",
+			builder: func(t *testing.T) *MessageBuilder {
+				return NewAudio(utils.FromBytes(testVoice(t), "voice.wav")).PTT()
+			},
+			upload: whatsmeow.MediaAudio,
+			check: func(t *testing.T, msg *waProto.Message) {
+				audio := msg.GetAudioMessage()
+				if !audio.GetPTT() {
+					t.Errorf("voice message isn't PTT: %v", audio)
+				}
+				if hasFFmpeg() && (audio.GetMimetype() != utils.VoiceNoteMimeType || len(audio.GetWaveform()) == 0) {
+					t.Errorf("voice message wasn't converted: %v", audio)
+				}
+			},
+		},
+		{
+			name: "document",
+			builder: func(t *testing.T) *MessageBuilder {
+				return NewDocument(utils.FromBytes([]byte("%PDF-1.4\n"), "report.pdf")).FileName("Q3.pdf").Caption("numbers")
+			},
+			upload: whatsmeow.MediaDocument,
+			check: func(t *testing.T, msg *waProto.Message) {
+				doc := msg.GetDocumentMessage()
+				if doc.GetFileName() != "Q3.pdf" || doc.GetMimetype() != "application/pdf" || doc.GetCaption() != "numbers" {
+					t.Errorf("unexpected document message %v", doc)
+				}
+			},
+		},
+		{
+			name: "document name from source",
+			builder: func(t *testing.T) *MessageBuilder {
+				return NewDocument(utils.FromBytes([]byte("%PDF-1.4\n"), "report.pdf"))
+			},
+			upload: whatsmeow.MediaDocument,
+			check: func(t *testing.T, msg *waProto.Message) {
+				if name := msg.GetDocumentMessage().GetFileName(); name != "report.pdf" {
+					t.Errorf("file name = %q", name)
+				}
+			},
+		},
+		{
+			name: "sticker",
+			builder: func(t *testing.T) *MessageBuilder {
+				return NewSticker(utils.FromBytes(testWebP(), "sticker.webp"))
+			},
+			upload: whatsmeow.MediaImage,
+			check: func(t *testing.T, msg *waProto.Message) {
+				sticker := msg.GetStickerMessage()
+				if sticker.GetMimetype() != "image/webp" || sticker.GetWidth() != 512 || sticker.GetHeight() != 512 || sticker.GetIsAnimated() {
+					t.Errorf("unexpected sticker message %v", sticker)
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			up := &fakeUploader{}
+			msg, err := tt.builder(t).Build(context.Background(), up)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(up.types) != 1 || up.types[0] != tt.upload {
+				t.Errorf("uploads = %v, want one %s", up.types, tt.upload)
+			}
+			tt.check(t, msg)
+		})
+	}
+}
+
+func TestBuildViewOnce(t *testing.T) {
+	tests := []struct {
+		name    string
+		builder func(t *testing.T) *MessageBuilder
+		check   func(t *testing.T, msg *waProto.Message)
+	}{
+		{
+			name: "image",
+			builder: func(t *testing.T) *MessageBuilder {
+				return NewImage(utils.FromBytes(testPNG(t, 8, 8), "photo.png")).ViewOnce()
+			},
+			check: func(t *testing.T, msg *waProto.Message) {
+				inner := msg.GetViewOnceMessage().GetMessage()
+				if !inner.GetImageMessage().GetViewOnce() {
+					t.Errorf("image should be wrapped in ViewOnceMessage, got %v", msg)
+				}
+			},
+		},
+		{
+			name: "video",
+			builder: func(t *testing.T) *MessageBuilder {
+				return NewVideo(utils.FromBytes(testVideo(t), "clip.mp4")).ViewOnce()
+			},
+			check: func(t *testing.T, msg *waProto.Message) {
+				inner := msg.GetViewOnceMessage().GetMessage()
+				if !inner.GetVideoMessage().GetViewOnce() {
+					t.Errorf("video should be wrapped in ViewOnceMessage, got %v", msg)
+				}
+			},
+		},
+		{
+			name: "voice",
+			builder: func(t *testing.T) *MessageBuilder {
+				return NewAudio(utils.FromBytes(testVoice(t), "voice.wav")).PTT().ViewOnce()
+			},
+			check: func(t *testing.T, msg *waProto.Message) {
+				if msg.ViewOnceMessage != nil {
+					t.Error("voice notes must not use ViewOnceMessage")
+				}
+				inner := msg.GetViewOnceMessageV2Extension().GetMessage()
+				if !inner.GetAudioMessage().GetViewOnce() || !inner.GetAudioMessage().GetPTT() {
+					t.Errorf("voice should be wrapped in ViewOnceMessageV2Extension, got %v", msg)
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg, err := tt.builder(t).Build(context.Background(), &fakeUploader{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			tt.check(t, msg)
+		})
+	}
+}
+
+func TestBuildViewOnceUnsupported(t *testing.T) {
+	builders := map[string]*MessageBuilder{
+		"text":     NewText("hi").ViewOnce(),
+		"audio":    NewAudio(utils.FromBytes([]byte("ID3\x03\x00\x00\x00\x00\x00\x00"), "song.mp3")).ViewOnce(),
+		"document": NewDocument(utils.FromBytes([]byte("%PDF-1.4\n"), "report.pdf")).ViewOnce(),
+	}
+	for name, builder := range builders {
+		up := &fakeUploader{}
+		if _, err := builder.Build(context.Background(), up); err == nil {
+			t.Errorf("%s: expected an error for view once", name)
+		}
+		if len(up.types) != 0 {
+			t.Errorf("%s: uploaded before rejecting view once", name)
+		}
+	}
+}
+
+func TestBuildPoll(t *testing.T) {
+	tests := []struct {
+		onlyOnce   bool
+		selectable uint32
+	}{
+		{onlyOnce: true, selectable: 1},
+		{onlyOnce: false, selectable: 0},
+	}
+	for _, tt := range tests {
+		msg, err := NewPoll("Lunch?", []string{"Pizza", "Sushi"}, tt.onlyOnce).Build(context.Background(), &fakeUploader{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		poll := msg.GetPollCreationMessage()
+		if poll.GetName() != "Lunch?" || len(poll.GetOptions()) != 2 || poll.GetOptions()[1].GetOptionName() != "Sushi" {
+			t.Errorf("unexpected poll %v", poll)
+		}
+		if poll.GetSelectableOptionsCount() != tt.selectable {
+			t.Errorf("onlyOnce %v: selectable = %d, want %d", tt.onlyOnce, poll.GetSelectableOptionsCount(), tt.selectable)
+		}
+	}
+}
+
+func TestBuildContacts(t *testing.T) {
+	alice := NewContact("Alice").AddPhone("+1 555 0100", "CELL")
+	bob := NewContact("Bob").AddPhone("+1 555 0101")
+
+	msg, err := NewContacts(alice).Build(context.Background(), &fakeUploader{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if card := msg.GetContactMessage(); card.GetDisplayName() != "Alice" || card.GetVcard() != alice.VCard() {
+		t.Errorf("unexpected contact message %v", card)
+	}
+
+	msg, err = NewContacts(alice, bob).Build(context.Background(), &fakeUploader{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	list := msg.GetContactsArrayMessage()
+	if list.GetDisplayName() != "2 contacts" || len(list.GetContacts()) != 2 || list.GetContacts()[1].GetDisplayName() != "Bob" {
+		t.Errorf("unexpected contacts message %v", list)
+	}
+
+	if _, err := NewContacts().Build(context.Background(), &fakeUploader{}); err == nil {
+		t.Error("expected an error without contacts")
+	}
+}
+
+func TestBuildExpiration(t *testing.T) {
+	week := 7 * 24 * time.Hour
+	tests := []struct {
+		name    string
+		builder func(t *testing.T) *MessageBuilder
+		context func(msg *waProto.Message) *waProto.ContextInfo
+		want    uint32
+	}{
+		{
+			name:    "text",
+			builder: func(t *testing.T) *MessageBuilder { return NewText("hi").Expiration(week) },
+			context: func(msg *waProto.Message) *waProto.ContextInfo { return msg.GetExtendedTextMessage().GetContextInfo() },
+			want:    604800,
+		},
+		{
+			name:    "inherited",
+			builder: func(t *testing.T) *MessageBuilder { return NewText("hi").InheritExpiration(24 * time.Hour) },
+			context: func(msg *waProto.Message) *waProto.ContextInfo { return msg.GetExtendedTextMessage().GetContextInfo() },
+			want:    86400,
+		},
+		{
+			name:    "explicit wins over inherited",
+			builder: func(t *testing.T) *MessageBuilder { return NewText("hi").Expiration(0).InheritExpiration(week) },
+			context: func(msg *waProto.Message) *waProto.ContextInfo { return msg.GetExtendedTextMessage().GetContextInfo() },
+			want:    0,
+		},
+		{
+			name: "image",
+			builder: func(t *testing.T) *MessageBuilder {
+				return NewImage(utils.FromBytes(testPNG(t, 8, 8), "photo.png")).Expiration(week)
+			},
+			context: func(msg *waProto.Message) *waProto.ContextInfo { return msg.GetImageMessage().GetContextInfo() },
+			want:    604800,
+		},
+		{
+			name:    "poll",
+			builder: func(t *testing.T) *MessageBuilder { return NewPoll("?", []string{"a", "b"}, true).Expiration(week) },
+			context: func(msg *waProto.Message) *waProto.ContextInfo { return msg.GetPollCreationMessage().GetContextInfo() },
+			want:    604800,
+		},
+		{
+			name:    "contact",
+			builder: func(t *testing.T) *MessageBuilder { return NewContacts(NewContact("Alice")).Expiration(week) },
+			context: func(msg *waProto.Message) *waProto.ContextInfo { return msg.GetContactMessage().GetContextInfo() },
+			want:    604800,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg, err := tt.builder(t).Build(context.Background(), &fakeUploader{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := tt.context(msg).GetExpiration(); got != tt.want {
+				t.Errorf("expiration = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,292 @@
+package messages
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	waProto "go.mau.fi/whatsmeow/binary/proto"
+	"google.golang.org/protobuf/proto"
+)
+
+// Phone types understood by WhatsApp's contact cards
+const (
+	PhoneCell = "CELL"
+	PhoneWork = "WORK"
+	PhoneHome = "HOME"
+	PhoneMain = "MAIN"
+)
+
+// ContactPhone is one phone number on a contact card
+type ContactPhone struct {
+	Number string
+	// Type is one of the Phone* constants. It defaults to PhoneCell.
+	Type string
+	// WhatsAppID is the number's WhatsApp user (digits only). Recipients get message and call buttons
+	// only when it is set; AddPhone fills it in from the number.
+	WhatsAppID string
+}
+
+// Contact is a person or business as shared in a contact card
+type Contact struct {
+	Name         string
+	Organization string
+	Phones       []ContactPhone
+	Emails       []string
+	URLs         []string
+}
+
+// NewContact starts a contact card with the name shown in the chat
+func NewContact(name string) *Contact {
+	return &Contact{Name: name}
+}
+
+// SetOrganization sets the company the contact works for
+func (c *Contact) SetOrganization(organization string) *Contact {
+	c.Organization = organization
+	return c
+}
+
+// AddPhone adds a phone number in international format with one of the Phone* types.
+// The number is linked to its WhatsApp account so recipients can message it directly.
+func (c *Contact) AddPhone(number string, phoneType ...string) *Contact {
+	phone := ContactPhone{Number: number, Type: PhoneCell}
+	if len(phoneType) > 0 && phoneType[0] != "" {
+		phone.Type = strings.ToUpper(phoneType[0])
+	}
+	phone.WhatsAppID = strings.Map(func(r rune) rune {
+		if unicode.IsDigit(r) {
+			return r
+		}
+		return -1
+	}, number)
+	c.Phones = append(c.Phones, phone)
+	return c
+}
+
+// AddEmail adds an email address
+func (c *Contact) AddEmail(email string) *Contact {
+	c.Emails = append(c.Emails, email)
+	return c
+}
+
+// AddURL adds a website
+func (c *Contact) AddURL(url string) *Contact {
+	c.URLs = append(c.URLs, url)
+	return c
+}
+
+// VCard renders the contact as the vCard 3.0 text WhatsApp sends
+func (c *Contact) VCard() string {
+	var b strings.Builder
+	b.WriteString("BEGIN:VCARD\r\nVERSION:3.0\r\n")
+	fmt.Fprintf(&b, "N:;%s;;;\r\n", escapeVCard(c.Name))
+	fmt.Fprintf(&b, "FN:%s\r\n", escapeVCard(c.Name))
+	if c.Organization != "" {
+		fmt.Fprintf(&b, "ORG:%s;\r\n", escapeVCard(c.Organization))
+	}
+	for _, phone := range c.Phones {
+		phoneType := phone.Type
+		if phoneType == "" {
+			phoneType = PhoneCell
+		}
+		b.WriteString("TEL;type=" + phoneType + ";type=VOICE")
+		if phone.WhatsAppID != "" {
+			b.WriteString(";waid=" + phone.WhatsAppID)
+		}
+		b.WriteString(":" + escapeVCard(phone.Number) + "\r\n")
+	}
+	for _, email := range c.Emails {
+		fmt.Fprintf(&b, "EMAIL;type=INTERNET:%s\r\n", escapeVCard(email))
+	}
+	for _, url := range c.URLs {
+		fmt.Fprintf(&b, "URL:%s\r\n", escapeVCard(url))
+	}
+	b.WriteString("END:VCARD")
+	return b.String()
+}
+
+func (c *Contact) message(contextInfo *waProto.ContextInfo) *waProto.ContactMessage {
+	return &waProto.ContactMessage{
+		DisplayName: proto.String(c.Name),
+		Vcard:       proto.String(c.VCard()),
+		ContextInfo: contextInfo,
+	}
+}
+
+var vCardEscaper = strings.NewReplacer(`\`, `\\`, "\r\n", `\n`, "\n", `\n`, ",", `\,`, ";", `\;`)
+
+func escapeVCard(value string) string {
+	return vCardEscaper.Replace(value)
+}
+
+func unescapeVCard(value string) string {
+	var b strings.Builder
+	escaped := false
+	for _, r := range value {
+		switch {
+		case escaped:
+			if r == 'n' || r == 'N' {
+				b.WriteRune('\n')
+			} else {
+				b.WriteRune(r)
+			}
+			escaped = false
+		case r == '\\':
+			escaped = true
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// splitVCard splits a structured value such as N or ORG on unescaped semicolons
+func splitVCard(value string) []string {
+	var parts []string
+	start, escaped := 0, false
+	for i, r := range value {
+		switch {
+		case escaped:
+			escaped = false
+		case r == '\\':
+			escaped = true
+		case r == ';':
+			parts = append(parts, unescapeVCard(value[start:i]))
+			start = i + 1
+		}
+	}
+	return append(parts, unescapeVCard(value[start:]))
+}
+
+// ParseVCard reads the first contact from vCard text as sent by WhatsApp and most phones
+func ParseVCard(vcard string) (*Contact, error) {
+	// Long lines are folded by starting the continuation with a space or tab
+	vcard = strings.NewReplacer("\r\n ", "", "\r\n\t", "", "\n ", "", "\n\t", "").Replace(vcard)
+
+	contact := &Contact{}
+	inCard := false
+	var structuredName string
+	for _, line := range strings.Split(vcard, "\n") {
+		line = strings.TrimRight(line, "\r")
+		colon := strings.IndexByte(line, ':')
+		if colon < 0 {
+			continue
+		}
+		params := strings.Split(line[:colon], ";")
+		// Grouped properties look like item1.TEL
+		name := strings.ToUpper(params[0])
+		if dot := strings.LastIndexByte(name, '.'); dot >= 0 {
+			name = name[dot+1:]
+		}
+		value := line[colon+1:]
+
+		switch {
+		case name == "BEGIN" && strings.EqualFold(value, "VCARD"):
+			inCard = true
+		case name == "END" && strings.EqualFold(value, "VCARD"):
+			if contact.Name == "" {
+				contact.Name = structuredName
+			}
+			return contact, nil
+		case !inCard:
+		case name == "FN":
+			contact.Name = unescapeVCard(value)
+		case name == "N":
+			// Family;Given;Additional;Prefix;Suffix, used when there is no FN
+			parts := splitVCard(value)
+			var names []string
+			for _, i := range []int{3, 1, 2, 0, 4} {
+				if i < len(parts) && parts[i] != "" {
+					names = append(names, parts[i])
+				}
+			}
+			structuredName = strings.Join(names, " ")
+		case name == "ORG":
+			contact.Organization = strings.TrimSpace(strings.Join(splitVCard(value), " "))
+		case name == "TEL":
+			phone := ContactPhone{Number: unescapeVCard(value)}
+			for _, param := range params[1:] {
+				key, paramValue, _ := strings.Cut(param, "=")
+				switch strings.ToLower(key) {
+				case "waid":
+					phone.WhatsAppID = paramValue
+				case "type":
+					// Types may be listed in one parameter, e.g. TYPE=CELL,VOICE
+					for _, phoneType := range strings.Split(strings.Trim(paramValue, `"`), ",") {
+						if upper := strings.ToUpper(strings.TrimSpace(phoneType)); upper != "" && upper != "VOICE" && phone.Type == "" {
+							phone.Type = upper
+						}
+					}
+				}
+			}
+			if phone.Type == "" {
+				phone.Type = PhoneCell
+			}
+			contact.Phones = append(contact.Phones, phone)
+		case name == "EMAIL":
+			contact.Emails = append(contact.Emails, unescapeVCard(value))
+		case name == "URL":
+			contact.URLs = append(contact.URLs, unescapeVCard(value))
+		}
+	}
+	if !inCard {
+		return nil, fmt.Errorf("no vCard found")
+	}
+	return nil, fmt.Errorf("vCard is not terminated")
+}
+
+// NewContacts starts a contact card message. One contact is sent as a ContactMessage, several as a ContactsArrayMessage.
+func NewContacts(contacts ...*Contact) *MessageBuilder {
+	return &MessageBuilder{kind: kindContact, contacts: contacts}
+}
+
+// buildContacts turns the builder's contacts into the message
+func (b *MessageBuilder) buildContacts(contextInfo *waProto.ContextInfo) (*waProto.Message, error) {
+	if len(b.contacts) == 0 {
+		return nil, fmt.Errorf("no contacts given")
+	}
+	for i, contact := range b.contacts {
+		if contact == nil {
+			return nil, fmt.Errorf("contact %d is nil", i+1)
+		}
+	}
+	if len(b.contacts) == 1 {
+		return &waProto.Message{ContactMessage: b.contacts[0].message(contextInfo)}, nil
+	}
+
+	cards := make([]*waProto.ContactMessage, len(b.contacts))
+	for i, contact := range b.contacts {
+		cards[i] = contact.message(nil)
+	}
+	return &waProto.Message{
+		ContactsArrayMessage: &waProto.ContactsArrayMessage{
+			DisplayName: proto.String(fmt.Sprintf("%d contacts", len(b.contacts))),
+			Contacts:    cards,
+			ContextInfo: contextInfo,
+		},
+	}, nil
+}
+
+// Contacts parses the contact cards in a contact or contacts message. Cards that can't be parsed
+// still come back with the display name WhatsApp shows for them.
+func (m *IncomingMessage) Contacts() []*Contact {
+	var cards []*waProto.ContactMessage
+	if card := m.Message.GetContactMessage(); card != nil {
+		cards = append(cards, card)
+	}
+	cards = append(cards, m.Message.GetContactsArrayMessage().GetContacts()...)
+
+	contacts := make([]*Contact, 0, len(cards))
+	for _, card := range cards {
+		contact, err := ParseVCard(card.GetVcard())
+		if err != nil {
+			contact = &Contact{}
+		}
+		if contact.Name == "" {
+			contact.Name = card.GetDisplayName()
+		}
+		contacts = append(contacts, contact)
+	}
+	return contacts
+}
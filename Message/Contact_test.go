@@ -0,0 +1,156 @@
+package messages
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestVCardRoundTrip(t *testing.T) {
+	tests := []struct {
+		name    string
+		contact *Contact
+	}{
+		{
+			name:    "name only",
+			contact: NewContact("Alice"),
+		},
+		{
+			name: "full card",
+			contact: NewContact("Bob Builder").SetOrganization("Builders, Inc.").
+				AddPhone("+1 555 0100").AddPhone("+44 20 7946 0958", PhoneWork).
+				AddEmail("bob@example.com").AddURL("https://example.com/bob"),
+		},
+		{
+			name:    "escaped characters",
+			contact: NewContact(`Doe; John, Jr. \ the "second"`).SetOrganization("A;B,C\\D").AddEmail("semi;colon@example.com"),
+		},
+		{
+			name:    "multi-line organization",
+			contact: NewContact("Carol").SetOrganization("First line\nSecond line"),
+		},
+		{
+			name:    "non-ASCII",
+			contact: NewContact("Zoë Åström 👩‍💻").AddPhone("+46 8 123 456", PhoneHome),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			vcard := tt.contact.VCard()
+			if !strings.HasPrefix(vcard, "BEGIN:VCARD\r\nVERSION:3.0\r\n") || !strings.HasSuffix(vcard, "END:VCARD") {
+				t.Errorf("unexpected vCard framing:\n%s", vcard)
+			}
+			parsed, err := ParseVCard(vcard)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(parsed, tt.contact) {
+				t.Errorf("round trip changed the contact\n got %+v\nwant %+v\nvCard:\n%s", parsed, tt.contact, vcard)
+			}
+		})
+	}
+}
+
+func TestVCardEscaping(t *testing.T) {
+	vcard := NewContact("a,b;c\\d\ne").VCard()
+	if !strings.Contains(vcard, "FN:a\\,b\\;c\\\\d\\ne\r\n") {
+		t.Errorf("name isn't escaped:\n%s", vcard)
+	}
+}
+
+func TestParseVCard(t *testing.T) {
+	tests := []struct {
+		name  string
+		vcard string
+		want  *Contact
+		err   bool
+	}{
+		{
+			name:  "folded lines",
+			vcard: "BEGIN:VCARD\r\nVERSION:3.0\r\nFN:A very long name that\r\n  got folded\r\nEMAIL:someone@exa\r\n\tmple.com\r\nEND:VCARD",
+			want:  &Contact{Name: "A very long name that got folded", Emails: []string{"someone@example.com"}},
+		},
+		{
+			name:  "LF line endings and folding",
+			vcard: "BEGIN:VCARD\nVERSION:3.0\nFN:Dan\nURL:https://exa\n mple.com\nEND:VCARD\n",
+			want:  &Contact{Name: "Dan", URLs: []string{"https://example.com"}},
+		},
+		{
+			name:  "name from N without FN",
+			vcard: "BEGIN:VCARD\r\nVERSION:3.0\r\nN:Doe;John;Q;Dr.;III\r\nEND:VCARD",
+			want:  &Contact{Name: "Dr. John Q Doe III"},
+		},
+		{
+			name:  "escaped structured name",
+			vcard: "BEGIN:VCARD\r\nVERSION:3.0\r\nN:O\\;Brien;Pat;;;\r\nEND:VCARD",
+			want:  &Contact{Name: "Pat O;Brien"},
+		},
+		{
+			name:  "grouped phone with waid",
+			vcard: "BEGIN:VCARD\r\nVERSION:3.0\r\nFN:Eve\r\nitem1.TEL;waid=15550100:+1 555-0100\r\nitem1.X-ABLabel:Mobile\r\nEND:VCARD",
+			want:  &Contact{Name: "Eve", Phones: []ContactPhone{{Number: "+1 555-0100", Type: PhoneCell, WhatsAppID: "15550100"}}},
+		},
+		{
+			name:  "comma separated types",
+			vcard: "BEGIN:VCARD\r\nVERSION:3.0\r\nFN:Finn\r\nTEL;TYPE=VOICE,work:+1 555 0101\r\nTEL;TYPE=\"CELL,VOICE\":+1 555 0102\r\nEND:VCARD",
+			want: &Contact{Name: "Finn", Phones: []ContactPhone{
+				{Number: "+1 555 0101", Type: PhoneWork},
+				{Number: "+1 555 0102", Type: PhoneCell},
+			}},
+		},
+		{
+			name:  "repeated type parameters",
+			vcard: "BEGIN:VCARD\r\nVERSION:3.0\r\nFN:Gus\r\nTEL;type=VOICE;type=HOME:+1 555 0103\r\nEND:VCARD",
+			want:  &Contact{Name: "Gus", Phones: []ContactPhone{{Number: "+1 555 0103", Type: PhoneHome}}},
+		},
+		{
+			name:  "only the first card",
+			vcard: "BEGIN:VCARD\r\nFN:First\r\nEND:VCARD\r\nBEGIN:VCARD\r\nFN:Second\r\nEND:VCARD",
+			want:  &Contact{Name: "First"},
+		},
+		{
+			name:  "lines outside the card are ignored",
+			vcard: "FN:Outside\r\nBEGIN:VCARD\r\nFN:Inside\r\nEND:VCARD",
+			want:  &Contact{Name: "Inside"},
+		},
+		{
+			name:  "no card",
+			vcard: "hello",
+			err:   true,
+		},
+		{
+			name:  "unterminated",
+			vcard: "BEGIN:VCARD\r\nFN:Half",
+			err:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseVCard(tt.vcard)
+			if tt.err {
+				if err == nil {
+					t.Fatalf("expected an error, got %+v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewContactsNil(t *testing.T) {
+	if _, err := NewContacts(NewContact("Alice"), nil).Build(context.Background(), &fakeUploader{}); err == nil {
+		t.Error("expected an error for a nil contact")
+	}
+	if _, err := NewContacts(nil).Build(context.Background(), &fakeUploader{}); err == nil {
+		t.Error("expected an error for a single nil contact")
+	}
+}
@@ -0,0 +1,252 @@
+package messages
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"os"
+	"path/filepath"
+	"strings"
+
+	utils "github.com/hacxk/easy-meow/Utils"
+
+	"go.mau.fi/whatsmeow"
+)
+
+// DefaultMaxDownloadSize is the largest media file downloaded when no limit is given
+const DefaultMaxDownloadSize = 100 << 20
+
+var (
+	// ErrNoMedia is returned when downloading a message that has no image, video, audio, document or sticker
+	ErrNoMedia = errors.New("message has no downloadable media")
+	// ErrMediaTooLarge is returned when media is bigger than the download limit
+	ErrMediaTooLarge = errors.New("media is larger than the download limit")
+	// ErrUnknownSize is returned when a size limit applies but the message doesn't say how big the media is
+	ErrUnknownSize = errors.New("media size is unknown")
+)
+
+// DownloadOptions limits what a download may do
+type DownloadOptions struct {
+	// MaxSize is the largest file accepted, in bytes. Zero means DefaultMaxDownloadSize, a negative value disables the limit.
+	// whatsmeow downloads media into memory, so the limit is enforced with the size the message declares
+	// and media that declares none is refused unless the limit is disabled.
+	MaxSize int64
+}
+
+// Well-known extensions, since mime.ExtensionsByType depends on the system and often picks odd ones like .jfif
+var mediaExtensions = map[string]string{
+	"image/jpeg":      ".jpg",
+	"image/png":       ".png",
+	"image/webp":      ".webp",
+	"image/gif":       ".gif",
+	"video/mp4":       ".mp4",
+	"video/3gpp":      ".3gp",
+	"video/quicktime": ".mov",
+	"audio/ogg":       ".ogg",
+	"audio/mpeg":      ".mp3",
+	"audio/mp4":       ".m4a",
+	"audio/aac":       ".aac",
+	"audio/amr":       ".amr",
+	"application/pdf": ".pdf",
+}
+
+// downloadableMedia is a media message along with the fields needed to name and check it
+type downloadableMedia interface {
+	whatsmeow.DownloadableMessage
+	GetMimetype() string
+	GetFileLength() uint64
+}
+
+// Media returns the downloadable part of the message, or nil when it has none
+func (m *IncomingMessage) Media() whatsmeow.DownloadableMessage {
+	if media := m.media(); media != nil {
+		return media
+	}
+	return nil
+}
+
+// HasMedia reports whether the message carries an image, video, audio, document or sticker
+func (m *IncomingMessage) HasMedia() bool {
+	return m.media() != nil
+}
+
+func (m *IncomingMessage) media() downloadableMedia {
+	msg := m.Message
+	switch {
+	case msg.GetImageMessage() != nil:
+		return msg.GetImageMessage()
+	case msg.GetVideoMessage() != nil:
+		return msg.GetVideoMessage()
+	case msg.GetAudioMessage() != nil:
+		return msg.GetAudioMessage()
+	case msg.GetDocumentMessage() != nil:
+		return msg.GetDocumentMessage()
+	case msg.GetStickerMessage() != nil:
+		return msg.GetStickerMessage()
+	default:
+		return nil
+	}
+}
+
+// MediaMimeType returns the MIME type of the media without parameters, or an empty string
+func (m *IncomingMessage) MediaMimeType() string {
+	media := m.media()
+	if media == nil {
+		return ""
+	}
+	mimeType, _, err := mime.ParseMediaType(media.GetMimetype())
+	if err != nil {
+		return media.GetMimetype()
+	}
+	return mimeType
+}
+
+// MediaFileName returns a safe file name for the media: the document's own name when it has one,
+// otherwise the message ID with an extension derived from the MIME type
+func (m *IncomingMessage) MediaFileName() string {
+	media := m.media()
+	if media == nil {
+		return ""
+	}
+
+	extension := mediaExtension(m.MediaMimeType())
+	if document := m.Message.GetDocumentMessage(); document != nil {
+		if name := sanitizeFileName(document.GetFileName()); name != "" {
+			if filepath.Ext(name) == "" {
+				name += extension
+			}
+			return name
+		}
+	}
+	return sanitizeFileName(m.Info.ID) + extension
+}
+
+// Download writes the media of the message to w after checking its size, and returns the number of bytes written.
+// The file is held in memory while it is downloaded and decrypted; whatsmeow verifies its checksums.
+func (m *IncomingMessage) Download(ctx context.Context, client *whatsmeow.Client, w io.Writer, opts ...DownloadOptions) (int64, error) {
+	media := m.media()
+	if media == nil {
+		return 0, ErrNoMedia
+	}
+
+	limit := int64(DefaultMaxDownloadSize)
+	if len(opts) > 0 && opts[0].MaxSize != 0 {
+		limit = opts[0].MaxSize
+	}
+	// whatsmeow reads the whole file into memory, so the declared size is the only guard before downloading
+	if limit > 0 && media.GetFileLength() == 0 {
+		return 0, ErrUnknownSize
+	}
+	if limit > 0 && media.GetFileLength() > uint64(limit) {
+		return 0, fmt.Errorf("%w: %d bytes (limit %d)", ErrMediaTooLarge, media.GetFileLength(), limit)
+	}
+
+	// whatsmeow's Download has no context, so it is abandoned if ctx ends first
+	data, err := utils.AwaitContext(ctx, func() ([]byte, error) {
+		return client.Download(media)
+	})
+	if err != nil {
+		if ctx.Err() != nil {
+			return 0, err
+		}
+		return 0, fmt.Errorf("failed to download media: %w", err)
+	}
+
+	if limit > 0 && int64(len(data)) > limit {
+		return 0, fmt.Errorf("%w: %d bytes (limit %d)", ErrMediaTooLarge, len(data), limit)
+	}
+
+	n, err := io.Copy(w, bytes.NewReader(data))
+	if err != nil {
+		return n, fmt.Errorf("failed to write media: %w", err)
+	}
+	return n, nil
+}
+
+// DownloadBytes downloads the media of the message into memory
+func (m *IncomingMessage) DownloadBytes(ctx context.Context, client *whatsmeow.Client, opts ...DownloadOptions) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	if _, err := m.Download(ctx, client, buf, opts...); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// SaveTo downloads the media of the message into dir under MediaFileName and returns the full path.
+// An existing file is never overwritten; a numbered name is used instead.
+func (m *IncomingMessage) SaveTo(ctx context.Context, client *whatsmeow.Client, dir string, opts ...DownloadOptions) (string, error) {
+	if !m.HasMedia() {
+		return "", ErrNoMedia
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	file, path, err := createUnique(dir, m.MediaFileName())
+	if err != nil {
+		return "", err
+	}
+
+	_, err = m.Download(ctx, client, file, opts...)
+	closeErr := file.Close()
+	if err == nil && closeErr != nil {
+		err = fmt.Errorf("failed to write media: %w", closeErr)
+	}
+	if err != nil {
+		os.Remove(path)
+		return "", err
+	}
+	return path, nil
+}
+
+// createUnique creates name in dir, adding " (1)", " (2)"... before the extension if it is taken
+func createUnique(dir, name string) (*os.File, string, error) {
+	extension := filepath.Ext(name)
+	base := strings.TrimSuffix(name, extension)
+	for i := 0; i < 1000; i++ {
+		candidate := name
+		if i > 0 {
+			candidate = fmt.Sprintf("%s (%d)%s", base, i, extension)
+		}
+		path := filepath.Join(dir, candidate)
+		file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+		if err == nil {
+			return file, path, nil
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return nil, "", fmt.Errorf("failed to create file: %w", err)
+		}
+	}
+	return nil, "", fmt.Errorf("failed to find a free file name for %s", name)
+}
+
+func mediaExtension(mimeType string) string {
+	if extension, ok := mediaExtensions[mimeType]; ok {
+		return extension
+	}
+	if extensions, err := mime.ExtensionsByType(mimeType); err == nil && len(extensions) > 0 {
+		return extensions[0]
+	}
+	return ".bin"
+}
+
+// sanitizeFileName keeps only the base name and drops characters that are unsafe on common file systems
+func sanitizeFileName(name string) string {
+	name = strings.ReplaceAll(name, "\\", "/")
+	name = filepath.Base(name)
+	name = strings.Map(func(r rune) rune {
+		switch {
+		case r < 0x20, strings.ContainsRune(`<>:"/\|?*`, r):
+			return '_'
+		}
+		return r
+	}, name)
+	name = strings.Trim(name, ". ")
+	if name == "" || name == "_" {
+		return ""
+	}
+	return name
+}
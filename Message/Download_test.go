@@ -0,0 +1,126 @@
+package messages
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	waProto "go.mau.fi/whatsmeow/binary/proto"
+	"go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/types/events"
+)
+
+func testMediaMessage(id string, msg *waProto.Message) *IncomingMessage {
+	return NewIncomingMessage(&events.Message{Info: types.MessageInfo{ID: id}, Message: msg})
+}
+
+func testDocument(name, mimeType string) *waProto.Message {
+	return &waProto.Message{DocumentMessage: &waProto.DocumentMessage{FileName: strPtr(name), Mimetype: strPtr(mimeType)}}
+}
+
+func TestSanitizeFileName(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{name: "report.pdf", want: "report.pdf"},
+		{name: "../../etc/passwd", want: "passwd"},
+		{name: `..\..\Windows\system.ini`, want: "system.ini"},
+		{name: "/absolute/path/file.txt", want: "file.txt"},
+		{name: `a<b>c:d"e|f?g*h.txt`, want: "a_b_c_d_e_f_g_h.txt"},
+		{name: "tab\tand\nnewline.txt", want: "tab_and_newline.txt"},
+		{name: "  .hidden.  ", want: "hidden"},
+		{name: "..", want: ""},
+		{name: "../", want: ""},
+		{name: "", want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := sanitizeFileName(tt.name); got != tt.want {
+				t.Errorf("sanitizeFileName(%q) = %q, want %q", tt.name, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMediaFileName(t *testing.T) {
+	tests := []struct {
+		name string
+		id   string
+		msg  *waProto.Message
+		want string
+	}{
+		{name: "document name", id: "ID1", msg: testDocument("report.pdf", "application/pdf"), want: "report.pdf"},
+		{name: "document traversal", id: "ID1", msg: testDocument("../../secret.pdf", "application/pdf"), want: "secret.pdf"},
+		{name: "document without extension", id: "ID1", msg: testDocument("report", "application/pdf"), want: "report.pdf"},
+		{name: "document without name", id: "ID1", msg: testDocument("", "application/pdf"), want: "ID1.pdf"},
+		{name: "document with unsafe name", id: "ID1", msg: testDocument("..", "application/pdf"), want: "ID1.pdf"},
+		{name: "image", id: "ID2", msg: &waProto.Message{ImageMessage: &waProto.ImageMessage{Mimetype: strPtr("image/jpeg")}}, want: "ID2.jpg"},
+		{name: "voice note with codec", id: "ID3", msg: &waProto.Message{AudioMessage: &waProto.AudioMessage{Mimetype: strPtr("audio/ogg; codecs=opus")}}, want: "ID3.ogg"},
+		{name: "unknown type", id: "ID4", msg: &waProto.Message{VideoMessage: &waProto.VideoMessage{Mimetype: strPtr("application/x-unknown-type")}}, want: "ID4.bin"},
+		{name: "unsafe ID", id: "../ID5", msg: &waProto.Message{StickerMessage: &waProto.StickerMessage{Mimetype: strPtr("image/webp")}}, want: "ID5.webp"},
+		{name: "no media", id: "ID6", msg: &waProto.Message{Conversation: strPtr("hello")}, want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := testMediaMessage(tt.id, tt.msg).MediaFileName(); got != tt.want {
+				t.Errorf("MediaFileName = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCreateUnique(t *testing.T) {
+	dir := t.TempDir()
+	want := []string{"photo.jpg", "photo (1).jpg", "photo (2).jpg"}
+	for _, name := range want {
+		file, path, err := createUnique(dir, "photo.jpg")
+		if err != nil {
+			t.Fatal(err)
+		}
+		file.Close()
+		if path != filepath.Join(dir, name) {
+			t.Errorf("path = %q, want %q", path, filepath.Join(dir, name))
+		}
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != len(want) {
+		t.Errorf("%d files in the directory, want %d", len(entries), len(want))
+	}
+}
+
+func TestDownloadSizeLimit(t *testing.T) {
+	image := func(size uint64) *waProto.Message {
+		return &waProto.Message{ImageMessage: &waProto.ImageMessage{Mimetype: strPtr("image/jpeg"), FileLength: &size}}
+	}
+	tests := []struct {
+		name string
+		msg  *waProto.Message
+		opts []DownloadOptions
+		err  error
+	}{
+		{name: "no media", msg: &waProto.Message{Conversation: strPtr("hello")}, err: ErrNoMedia},
+		{name: "unknown size", msg: image(0), err: ErrUnknownSize},
+		{name: "over the default limit", msg: image(DefaultMaxDownloadSize + 1), err: ErrMediaTooLarge},
+		{name: "over a custom limit", msg: image(2048), opts: []DownloadOptions{{MaxSize: 1024}}, err: ErrMediaTooLarge},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// The checks run before the client is used, so none is needed
+			_, err := testMediaMessage("ID", tt.msg).Download(context.Background(), nil, io.Discard, tt.opts...)
+			if !errors.Is(err, tt.err) {
+				t.Errorf("error = %v, want %v", err, tt.err)
+			}
+		})
+	}
+}
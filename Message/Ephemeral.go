@@ -0,0 +1,84 @@
+package messages
+
+import (
+	"sync"
+	"time"
+
+	waProto "go.mau.fi/whatsmeow/binary/proto"
+	"go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/types/events"
+)
+
+// EphemeralTimers remembers the disappearing messages timer of each chat, learned from the messages
+// and group updates the client receives. It is safe for concurrent use.
+type EphemeralTimers struct {
+	mu     sync.RWMutex
+	timers map[types.JID]time.Duration
+}
+
+// NewEphemeralTimers creates an empty cache
+func NewEphemeralTimers() *EphemeralTimers {
+	return &EphemeralTimers{timers: make(map[types.JID]time.Duration)}
+}
+
+// Get returns the timer of a chat, zero when disappearing messages are off or the chat is unknown
+func (t *EphemeralTimers) Get(chat types.JID) time.Duration {
+	timer, _ := t.Lookup(chat)
+	return timer
+}
+
+// Lookup returns the timer of a chat and whether it is known
+func (t *EphemeralTimers) Lookup(chat types.JID) (time.Duration, bool) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	timer, ok := t.timers[chat.ToNonAD()]
+	return timer, ok
+}
+
+// Set records the timer of a chat. Zero records that disappearing messages are off.
+func (t *EphemeralTimers) Set(chat types.JID, timer time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.timers[chat.ToNonAD()] = timer
+}
+
+// Observe updates the chat's timer from an incoming message. Disappearing messages carry the timer
+// in their context info, and changing the setting sends a protocol message with the new value.
+func (t *EphemeralTimers) Observe(msg *IncomingMessage) {
+	if protocol := msg.Message.GetProtocolMessage(); protocol != nil {
+		if protocol.GetType() == waProto.ProtocolMessage_EPHEMERAL_SETTING {
+			t.Set(msg.Chat(), seconds(protocol.GetEphemeralExpiration()))
+		}
+		return
+	}
+	// Only disappearing messages say anything about the timer. Clients that haven't caught up with a change
+	// still send without one, so a message that stays doesn't mean the timer is off; that is only learned
+	// from the setting message and group info.
+	if expiration := msg.ContextInfo().GetExpiration(); expiration > 0 {
+		t.Set(msg.Chat(), seconds(expiration))
+	}
+}
+
+// ObserveGroup records the timer from group info, e.g. as returned by GetGroupInfo or GetJoinedGroups
+func (t *EphemeralTimers) ObserveGroup(info *types.GroupInfo) {
+	t.setGroup(info.JID, info.GroupEphemeral)
+}
+
+// ObserveGroupChange records the timer when a group's disappearing messages setting changes
+func (t *EphemeralTimers) ObserveGroupChange(evt *events.GroupInfo) {
+	if evt.Ephemeral != nil {
+		t.setGroup(evt.JID, *evt.Ephemeral)
+	}
+}
+
+func (t *EphemeralTimers) setGroup(group types.JID, ephemeral types.GroupEphemeral) {
+	if !ephemeral.IsEphemeral {
+		t.Set(group, 0)
+		return
+	}
+	t.Set(group, seconds(ephemeral.DisappearingTimer))
+}
+
+func seconds(n uint32) time.Duration {
+	return time.Duration(n) * time.Second
+}
@@ -0,0 +1,62 @@
+package messages
+
+import (
+	"testing"
+	"time"
+
+	waProto "go.mau.fi/whatsmeow/binary/proto"
+	"go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/types/events"
+)
+
+func TestEphemeralTimersObserve(t *testing.T) {
+	chat := types.NewJID("1234567890", types.DefaultUserServer)
+	expiration := func(n uint32) *uint32 { return &n }
+	setting := func(n uint32) *waProto.Message {
+		return &waProto.Message{ProtocolMessage: &waProto.ProtocolMessage{
+			Type:                waProto.ProtocolMessage_EPHEMERAL_SETTING.Enum(),
+			EphemeralExpiration: expiration(n),
+		}}
+	}
+	disappearing := func(n uint32) *waProto.Message {
+		return &waProto.Message{ExtendedTextMessage: &waProto.ExtendedTextMessage{
+			Text:        strPtr("hello"),
+			ContextInfo: &waProto.ContextInfo{Expiration: expiration(n)},
+		}}
+	}
+
+	tests := []struct {
+		name  string
+		known time.Duration
+		msg   *waProto.Message
+		want  time.Duration
+		ok    bool
+	}{
+		{name: "setting turned on", msg: setting(86400), want: 24 * time.Hour, ok: true},
+		{name: "setting turned off", known: 24 * time.Hour, msg: setting(0), want: 0, ok: true},
+		{name: "setting changed", known: 24 * time.Hour, msg: setting(604800), want: 7 * 24 * time.Hour, ok: true},
+		{name: "expiration from context info", msg: disappearing(604800), want: 7 * 24 * time.Hour, ok: true},
+		{name: "expiration in an ephemeral wrapper", msg: &waProto.Message{EphemeralMessage: &waProto.FutureProofMessage{Message: disappearing(86400)}}, want: 24 * time.Hour, ok: true},
+		{name: "no timer keeps the known one", known: 24 * time.Hour, msg: &waProto.Message{Conversation: strPtr("hello")}, want: 24 * time.Hour, ok: true},
+		{name: "no timer in an unknown chat", msg: &waProto.Message{Conversation: strPtr("hello")}, want: 0, ok: false},
+		{name: "other protocol message", known: 24 * time.Hour, msg: &waProto.Message{ProtocolMessage: &waProto.ProtocolMessage{Type: waProto.ProtocolMessage_REVOKE.Enum()}}, want: 24 * time.Hour, ok: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			timers := NewEphemeralTimers()
+			if tt.known > 0 {
+				timers.Set(chat, tt.known)
+			}
+			timers.Observe(NewIncomingMessage(&events.Message{
+				Info:    types.MessageInfo{MessageSource: types.MessageSource{Chat: chat}},
+				Message: tt.msg,
+			}))
+
+			got, ok := timers.Lookup(chat)
+			if got != tt.want || ok != tt.ok {
+				t.Errorf("Lookup = %v, %v, want %v, %v", got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
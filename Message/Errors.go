@@ -0,0 +1,30 @@
+package messages
+
+import (
+	"context"
+	"errors"
+
+	"go.mau.fi/whatsmeow"
+)
+
+// IsTimeout reports whether a send or upload failed because it ran out of time,
+// either through the caller's context deadline or whatsmeow's own response timeout.
+func IsTimeout(err error) bool {
+	return errors.Is(err, context.DeadlineExceeded) ||
+		errors.Is(err, whatsmeow.ErrMessageTimedOut) ||
+		errors.Is(err, whatsmeow.ErrIQTimedOut)
+}
+
+// IsCanceled reports whether a send or upload was stopped because its context was canceled.
+func IsCanceled(err error) bool {
+	return errors.Is(err, context.Canceled)
+}
+
+// IsRejected reports whether the WhatsApp server answered the request with an error,
+// as opposed to the request never completing.
+func IsRejected(err error) bool {
+	var iqErr *whatsmeow.IQError
+	return errors.Is(err, whatsmeow.ErrServerReturnedError) ||
+		errors.Is(err, whatsmeow.ErrUnknownServer) ||
+		errors.As(err, &iqErr)
+}
@@ -0,0 +1,291 @@
+package messages
+
+import (
+	"regexp"
+	"strings"
+
+	waProto "go.mau.fi/whatsmeow/binary/proto"
+	"go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/types/events"
+)
+
+// MessageType is the kind of content an incoming message carries
+type MessageType string
+
+const (
+	TypeText         MessageType = "text"
+	TypeImage        MessageType = "image"
+	TypeVideo        MessageType = "video"
+	TypeAudio        MessageType = "audio"
+	TypeVoice        MessageType = "voice"
+	TypeDocument     MessageType = "document"
+	TypeSticker      MessageType = "sticker"
+	TypeLocation     MessageType = "location"
+	TypeLiveLocation MessageType = "live_location"
+	TypeContact      MessageType = "contact"
+	TypeContacts     MessageType = "contacts"
+	TypePoll         MessageType = "poll"
+	TypePollVote     MessageType = "poll_vote"
+	TypeReaction     MessageType = "reaction"
+	TypeProtocol     MessageType = "protocol"
+	TypeUnknown      MessageType = "unknown"
+)
+
+var urlPattern = regexp.MustCompile(`(?i)\bhttps?://[^\s<>"]+|\bwww\.[^\s<>"]+`)
+
+// IncomingMessage wraps a whatsmeow message event with accessors that work the same for every message kind,
+// so handlers don't need to check Conversation, ExtendedTextMessage and every caption field themselves.
+type IncomingMessage struct {
+	// Event is the original whatsmeow event
+	Event *events.Message
+	Info  types.MessageInfo
+	// Message is the content with ephemeral, view-once and document-with-caption containers removed
+	Message *waProto.Message
+
+	viewOnce  bool
+	ephemeral bool
+}
+
+// NewIncomingMessage wraps a whatsmeow message event
+func NewIncomingMessage(evt *events.Message) *IncomingMessage {
+	msg := &IncomingMessage{
+		Event:     evt,
+		Info:      evt.Info,
+		viewOnce:  evt.IsViewOnce || evt.IsViewOnceV2 || evt.IsViewOnceV2Extension,
+		ephemeral: evt.IsEphemeral,
+	}
+	var viewOnce, ephemeral bool
+	msg.Message, viewOnce, ephemeral = unwrapMessage(evt.Message)
+	msg.viewOnce = msg.viewOnce || viewOnce
+	msg.ephemeral = msg.ephemeral || ephemeral
+	return msg
+}
+
+// unwrapMessage removes the containers WhatsApp puts around the actual content
+func unwrapMessage(msg *waProto.Message) (content *waProto.Message, viewOnce bool, ephemeral bool) {
+	for msg != nil {
+		switch {
+		case msg.GetEphemeralMessage().GetMessage() != nil:
+			msg = msg.GetEphemeralMessage().GetMessage()
+			ephemeral = true
+		case msg.GetViewOnceMessage().GetMessage() != nil:
+			msg = msg.GetViewOnceMessage().GetMessage()
+			viewOnce = true
+		case msg.GetViewOnceMessageV2().GetMessage() != nil:
+			msg = msg.GetViewOnceMessageV2().GetMessage()
+			viewOnce = true
+		case msg.GetViewOnceMessageV2Extension().GetMessage() != nil:
+			msg = msg.GetViewOnceMessageV2Extension().GetMessage()
+			viewOnce = true
+		case msg.GetDocumentWithCaptionMessage().GetMessage() != nil:
+			msg = msg.GetDocumentWithCaptionMessage().GetMessage()
+		default:
+			return msg, viewOnce, ephemeral
+		}
+	}
+	return &waProto.Message{}, viewOnce, ephemeral
+}
+
+// ID returns the message ID
+func (m *IncomingMessage) ID() types.MessageID {
+	return m.Info.ID
+}
+
+// Chat returns the chat the message was sent in
+func (m *IncomingMessage) Chat() types.JID {
+	return m.Info.Chat
+}
+
+// Sender returns who sent the message
+func (m *IncomingMessage) Sender() types.JID {
+	return m.Info.Sender
+}
+
+// IsFromMe reports whether the message was sent by the logged-in account
+func (m *IncomingMessage) IsFromMe() bool {
+	return m.Info.IsFromMe
+}
+
+// IsGroup reports whether the message was sent in a group chat
+func (m *IncomingMessage) IsGroup() bool {
+	return m.Info.IsGroup
+}
+
+// IsViewOnce reports whether the message was sent as view-once, either in a view-once container
+// or with the view-once flag on the media itself
+func (m *IncomingMessage) IsViewOnce() bool {
+	msg := m.Message
+	return m.viewOnce || msg.GetImageMessage().GetViewOnce() || msg.GetVideoMessage().GetViewOnce() || msg.GetAudioMessage().GetViewOnce()
+}
+
+// IsViewOnceMedia reports whether the message is a view-once image, video or voice note.
+// The media is unwrapped, so Download, DownloadBytes and SaveTo work on it like on any other message.
+func (m *IncomingMessage) IsViewOnceMedia() bool {
+	return m.IsViewOnce() && m.HasMedia()
+}
+
+// IsEphemeral reports whether the message was sent with disappearing messages on
+func (m *IncomingMessage) IsEphemeral() bool {
+	return m.ephemeral
+}
+
+// Type returns what kind of content the message carries
+func (m *IncomingMessage) Type() MessageType {
+	msg := m.Message
+	switch {
+	case msg.GetConversation() != "" || msg.GetExtendedTextMessage() != nil:
+		return TypeText
+	case msg.GetImageMessage() != nil:
+		return TypeImage
+	case msg.GetVideoMessage() != nil:
+		return TypeVideo
+	case msg.GetAudioMessage() != nil:
+		if msg.GetAudioMessage().GetPTT() {
+			return TypeVoice
+		}
+		return TypeAudio
+	case msg.GetDocumentMessage() != nil:
+		return TypeDocument
+	case msg.GetStickerMessage() != nil:
+		return TypeSticker
+	case msg.GetLocationMessage() != nil:
+		return TypeLocation
+	case msg.GetLiveLocationMessage() != nil:
+		return TypeLiveLocation
+	case msg.GetContactMessage() != nil:
+		return TypeContact
+	case msg.GetContactsArrayMessage() != nil:
+		return TypeContacts
+	case msg.GetPollCreationMessage() != nil || msg.GetPollCreationMessageV2() != nil || msg.GetPollCreationMessageV3() != nil:
+		return TypePoll
+	case msg.GetPollUpdateMessage() != nil:
+		return TypePollVote
+	case msg.GetReactionMessage() != nil:
+		return TypeReaction
+	case msg.GetProtocolMessage() != nil:
+		return TypeProtocol
+	default:
+		return TypeUnknown
+	}
+}
+
+// Text returns the text of the message: the body of text messages, the caption of media,
+// the question of polls and the emoji of reactions. It is empty when there is none.
+func (m *IncomingMessage) Text() string {
+	msg := m.Message
+	switch {
+	case msg.GetConversation() != "":
+		return msg.GetConversation()
+	case msg.GetExtendedTextMessage() != nil:
+		return msg.GetExtendedTextMessage().GetText()
+	case msg.GetPollCreationMessage() != nil:
+		return msg.GetPollCreationMessage().GetName()
+	case msg.GetPollCreationMessageV2() != nil:
+		return msg.GetPollCreationMessageV2().GetName()
+	case msg.GetPollCreationMessageV3() != nil:
+		return msg.GetPollCreationMessageV3().GetName()
+	case msg.GetReactionMessage() != nil:
+		return msg.GetReactionMessage().GetText()
+	default:
+		return m.Caption()
+	}
+}
+
+// Caption returns the caption of image, video and document messages
+func (m *IncomingMessage) Caption() string {
+	msg := m.Message
+	switch {
+	case msg.GetImageMessage() != nil:
+		return msg.GetImageMessage().GetCaption()
+	case msg.GetVideoMessage() != nil:
+		return msg.GetVideoMessage().GetCaption()
+	case msg.GetDocumentMessage() != nil:
+		return msg.GetDocumentMessage().GetCaption()
+	default:
+		return ""
+	}
+}
+
+// ContextInfo returns the reply and mention information of the message, whatever its kind
+func (m *IncomingMessage) ContextInfo() *waProto.ContextInfo {
+	msg := m.Message
+	switch {
+	case msg.GetExtendedTextMessage() != nil:
+		return msg.GetExtendedTextMessage().GetContextInfo()
+	case msg.GetImageMessage() != nil:
+		return msg.GetImageMessage().GetContextInfo()
+	case msg.GetVideoMessage() != nil:
+		return msg.GetVideoMessage().GetContextInfo()
+	case msg.GetAudioMessage() != nil:
+		return msg.GetAudioMessage().GetContextInfo()
+	case msg.GetDocumentMessage() != nil:
+		return msg.GetDocumentMessage().GetContextInfo()
+	case msg.GetStickerMessage() != nil:
+		return msg.GetStickerMessage().GetContextInfo()
+	case msg.GetLocationMessage() != nil:
+		return msg.GetLocationMessage().GetContextInfo()
+	case msg.GetLiveLocationMessage() != nil:
+		return msg.GetLiveLocationMessage().GetContextInfo()
+	case msg.GetContactMessage() != nil:
+		return msg.GetContactMessage().GetContextInfo()
+	case msg.GetContactsArrayMessage() != nil:
+		return msg.GetContactsArrayMessage().GetContextInfo()
+	case msg.GetPollCreationMessage() != nil:
+		return msg.GetPollCreationMessage().GetContextInfo()
+	default:
+		return nil
+	}
+}
+
+// QuotedMessage returns the unwrapped content of the message this one replies to, or nil
+func (m *IncomingMessage) QuotedMessage() *waProto.Message {
+	quoted := m.ContextInfo().GetQuotedMessage()
+	if quoted == nil {
+		return nil
+	}
+	content, _, _ := unwrapMessage(quoted)
+	return content
+}
+
+// QuotedMessageID returns the ID of the message this one replies to, or an empty string
+func (m *IncomingMessage) QuotedMessageID() types.MessageID {
+	return m.ContextInfo().GetStanzaID()
+}
+
+// QuotedSender returns who sent the message this one replies to. The JID is empty when it isn't a reply.
+func (m *IncomingMessage) QuotedSender() types.JID {
+	participant := m.ContextInfo().GetParticipant()
+	if participant == "" {
+		return types.EmptyJID
+	}
+	jid, err := types.ParseJID(participant)
+	if err != nil {
+		return types.EmptyJID
+	}
+	return jid
+}
+
+// Mentions returns the users tagged in the message
+func (m *IncomingMessage) Mentions() []types.JID {
+	var mentions []types.JID
+	for _, mentioned := range m.ContextInfo().GetMentionedJID() {
+		jid, err := types.ParseJID(mentioned)
+		if err == nil {
+			mentions = append(mentions, jid)
+		}
+	}
+	return mentions
+}
+
+// URLs returns the links in the message text, in order of appearance
+func (m *IncomingMessage) URLs() []string {
+	found := urlPattern.FindAllString(m.Text(), -1)
+	if matched := m.Message.GetExtendedTextMessage().GetMatchedText(); matched != "" && len(found) == 0 {
+		found = append(found, matched)
+	}
+	for i, link := range found {
+		// Sentence punctuation right after a link isn't part of it
+		found[i] = strings.TrimRight(link, ".,!?;:)]}'")
+	}
+	return found
+}
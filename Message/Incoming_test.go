@@ -0,0 +1,232 @@
+package messages
+
+import (
+	"reflect"
+	"testing"
+
+	waProto "go.mau.fi/whatsmeow/binary/proto"
+	"go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/types/events"
+)
+
+func testIncoming(msg *waProto.Message) *IncomingMessage {
+	return NewIncomingMessage(&events.Message{Message: msg})
+}
+
+func wrap(msg *waProto.Message) *waProto.FutureProofMessage {
+	return &waProto.FutureProofMessage{Message: msg}
+}
+
+func TestUnwrapMessage(t *testing.T) {
+	text := &waProto.Message{Conversation: strPtr("hello")}
+	image := &waProto.Message{ImageMessage: &waProto.ImageMessage{Caption: strPtr("photo")}}
+	document := &waProto.Message{DocumentMessage: &waProto.DocumentMessage{Caption: strPtr("report")}}
+
+	tests := []struct {
+		name      string
+		msg       *waProto.Message
+		want      *waProto.Message
+		viewOnce  bool
+		ephemeral bool
+	}{
+		{name: "plain", msg: text, want: text},
+		{name: "ephemeral", msg: &waProto.Message{EphemeralMessage: wrap(text)}, want: text, ephemeral: true},
+		{name: "view-once", msg: &waProto.Message{ViewOnceMessage: wrap(image)}, want: image, viewOnce: true},
+		{name: "view-once v2", msg: &waProto.Message{ViewOnceMessageV2: wrap(image)}, want: image, viewOnce: true},
+		{name: "view-once v2 extension", msg: &waProto.Message{ViewOnceMessageV2Extension: wrap(image)}, want: image, viewOnce: true},
+		{name: "document with caption", msg: &waProto.Message{DocumentWithCaptionMessage: wrap(document)}, want: document},
+		{
+			name:      "ephemeral view-once",
+			msg:       &waProto.Message{EphemeralMessage: wrap(&waProto.Message{ViewOnceMessageV2: wrap(image)})},
+			want:      image,
+			viewOnce:  true,
+			ephemeral: true,
+		},
+		{
+			name:      "ephemeral document with caption",
+			msg:       &waProto.Message{EphemeralMessage: wrap(&waProto.Message{DocumentWithCaptionMessage: wrap(document)})},
+			want:      document,
+			ephemeral: true,
+		},
+		{name: "empty container", msg: &waProto.Message{EphemeralMessage: &waProto.FutureProofMessage{}}, want: &waProto.Message{EphemeralMessage: &waProto.FutureProofMessage{}}},
+		{name: "nil", msg: nil, want: &waProto.Message{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			content, viewOnce, ephemeral := unwrapMessage(tt.msg)
+			if !reflect.DeepEqual(content, tt.want) {
+				t.Errorf("content = %v, want %v", content, tt.want)
+			}
+			if viewOnce != tt.viewOnce || ephemeral != tt.ephemeral {
+				t.Errorf("viewOnce, ephemeral = %v, %v, want %v, %v", viewOnce, ephemeral, tt.viewOnce, tt.ephemeral)
+			}
+		})
+	}
+}
+
+func TestIncomingType(t *testing.T) {
+	tests := []struct {
+		name string
+		msg  *waProto.Message
+		want MessageType
+	}{
+		{name: "conversation", msg: &waProto.Message{Conversation: strPtr("hi")}, want: TypeText},
+		{name: "extended text", msg: &waProto.Message{ExtendedTextMessage: &waProto.ExtendedTextMessage{Text: strPtr("hi")}}, want: TypeText},
+		{name: "image", msg: &waProto.Message{ImageMessage: &waProto.ImageMessage{}}, want: TypeImage},
+		{name: "view-once image", msg: &waProto.Message{ViewOnceMessageV2: wrap(&waProto.Message{ImageMessage: &waProto.ImageMessage{}})}, want: TypeImage},
+		{name: "video", msg: &waProto.Message{VideoMessage: &waProto.VideoMessage{}}, want: TypeVideo},
+		{name: "audio", msg: &waProto.Message{AudioMessage: &waProto.AudioMessage{}}, want: TypeAudio},
+		{name: "voice", msg: &waProto.Message{AudioMessage: &waProto.AudioMessage{PTT: boolPtr(true)}}, want: TypeVoice},
+		{name: "document with caption", msg: &waProto.Message{DocumentWithCaptionMessage: wrap(&waProto.Message{DocumentMessage: &waProto.DocumentMessage{}})}, want: TypeDocument},
+		{name: "sticker", msg: &waProto.Message{StickerMessage: &waProto.StickerMessage{}}, want: TypeSticker},
+		{name: "location", msg: &waProto.Message{LocationMessage: &waProto.LocationMessage{}}, want: TypeLocation},
+		{name: "live location", msg: &waProto.Message{LiveLocationMessage: &waProto.LiveLocationMessage{}}, want: TypeLiveLocation},
+		{name: "contact", msg: &waProto.Message{ContactMessage: &waProto.ContactMessage{}}, want: TypeContact},
+		{name: "contacts", msg: &waProto.Message{ContactsArrayMessage: &waProto.ContactsArrayMessage{}}, want: TypeContacts},
+		{name: "poll v3", msg: &waProto.Message{PollCreationMessageV3: &waProto.PollCreationMessage{}}, want: TypePoll},
+		{name: "poll vote", msg: &waProto.Message{PollUpdateMessage: &waProto.PollUpdateMessage{}}, want: TypePollVote},
+		{name: "reaction", msg: &waProto.Message{ReactionMessage: &waProto.ReactionMessage{}}, want: TypeReaction},
+		{name: "protocol", msg: &waProto.Message{ProtocolMessage: &waProto.ProtocolMessage{}}, want: TypeProtocol},
+		{name: "empty", msg: &waProto.Message{}, want: TypeUnknown},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := testIncoming(tt.msg).Type(); got != tt.want {
+				t.Errorf("Type = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIncomingText(t *testing.T) {
+	tests := []struct {
+		name    string
+		msg     *waProto.Message
+		text    string
+		caption string
+	}{
+		{name: "conversation", msg: &waProto.Message{Conversation: strPtr("plain")}, text: "plain"},
+		{name: "extended text", msg: &waProto.Message{ExtendedTextMessage: &waProto.ExtendedTextMessage{Text: strPtr("rich")}}, text: "rich"},
+		{name: "ephemeral text", msg: &waProto.Message{EphemeralMessage: wrap(&waProto.Message{ExtendedTextMessage: &waProto.ExtendedTextMessage{Text: strPtr("gone soon")}})}, text: "gone soon"},
+		{name: "image caption", msg: &waProto.Message{ImageMessage: &waProto.ImageMessage{Caption: strPtr("photo")}}, text: "photo", caption: "photo"},
+		{name: "view-once video caption", msg: &waProto.Message{ViewOnceMessage: wrap(&waProto.Message{VideoMessage: &waProto.VideoMessage{Caption: strPtr("clip")}})}, text: "clip", caption: "clip"},
+		{name: "document caption", msg: &waProto.Message{DocumentWithCaptionMessage: wrap(&waProto.Message{DocumentMessage: &waProto.DocumentMessage{Caption: strPtr("report")}})}, text: "report", caption: "report"},
+		{name: "poll question", msg: &waProto.Message{PollCreationMessage: &waProto.PollCreationMessage{Name: strPtr("Lunch?")}}, text: "Lunch?"},
+		{name: "reaction emoji", msg: &waProto.Message{ReactionMessage: &waProto.ReactionMessage{Text: strPtr("👍")}}, text: "👍"},
+		{name: "sticker", msg: &waProto.Message{StickerMessage: &waProto.StickerMessage{}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg := testIncoming(tt.msg)
+			if got := msg.Text(); got != tt.text {
+				t.Errorf("Text = %q, want %q", got, tt.text)
+			}
+			if got := msg.Caption(); got != tt.caption {
+				t.Errorf("Caption = %q, want %q", got, tt.caption)
+			}
+		})
+	}
+}
+
+func TestIncomingQuotedSenderAndMentions(t *testing.T) {
+	alice := types.NewJID("111", types.DefaultUserServer)
+	bob := types.NewJID("222", types.DefaultUserServer)
+	text := func(info *waProto.ContextInfo) *waProto.Message {
+		return &waProto.Message{ExtendedTextMessage: &waProto.ExtendedTextMessage{Text: strPtr("hi"), ContextInfo: info}}
+	}
+
+	tests := []struct {
+		name     string
+		msg      *waProto.Message
+		quoted   types.JID
+		mentions []types.JID
+	}{
+		{name: "no context", msg: &waProto.Message{Conversation: strPtr("hi")}, quoted: types.EmptyJID},
+		{name: "reply", msg: text(&waProto.ContextInfo{Participant: strPtr(alice.String())}), quoted: alice},
+		{name: "invalid participant", msg: text(&waProto.ContextInfo{Participant: strPtr("1:x@s.whatsapp.net")}), quoted: types.EmptyJID},
+		{
+			name:     "mentions",
+			msg:      text(&waProto.ContextInfo{MentionedJID: []string{alice.String(), "1:x@s.whatsapp.net", bob.String()}}),
+			quoted:   types.EmptyJID,
+			mentions: []types.JID{alice, bob},
+		},
+		{
+			name:     "caption reply with mention",
+			msg:      &waProto.Message{ImageMessage: &waProto.ImageMessage{ContextInfo: &waProto.ContextInfo{Participant: strPtr(bob.String()), MentionedJID: []string{alice.String()}}}},
+			quoted:   bob,
+			mentions: []types.JID{alice},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg := testIncoming(tt.msg)
+			if got := msg.QuotedSender(); got != tt.quoted {
+				t.Errorf("QuotedSender = %v, want %v", got, tt.quoted)
+			}
+			if got := msg.Mentions(); !reflect.DeepEqual(got, tt.mentions) {
+				t.Errorf("Mentions = %v, want %v", got, tt.mentions)
+			}
+		})
+	}
+}
+
+func TestIncomingURLs(t *testing.T) {
+	tests := []struct {
+		name string
+		msg  *waProto.Message
+		want []string
+	}{
+		{name: "no links", msg: &waProto.Message{Conversation: strPtr("nothing here")}},
+		{name: "one link", msg: &waProto.Message{Conversation: strPtr("see https://example.com/page")}, want: []string{"https://example.com/page"}},
+		{name: "trailing punctuation", msg: &waProto.Message{Conversation: strPtr("Read https://example.com/a. Then www.example.org/b!")}, want: []string{"https://example.com/a", "www.example.org/b"}},
+		{name: "in parentheses", msg: &waProto.Message{Conversation: strPtr("(see http://example.com/x), ok?")}, want: []string{"http://example.com/x"}},
+		{name: "query kept", msg: &waProto.Message{Conversation: strPtr("https://example.com/?q=1&r=2")}, want: []string{"https://example.com/?q=1&r=2"}},
+		{name: "caption", msg: &waProto.Message{ImageMessage: &waProto.ImageMessage{Caption: strPtr("from https://example.com/photo,")}}, want: []string{"https://example.com/photo"}},
+		{
+			name: "matched text without a link in the text",
+			msg:  &waProto.Message{ExtendedTextMessage: &waProto.ExtendedTextMessage{Text: strPtr("preview only"), MatchedText: strPtr("https://example.com")}},
+			want: []string{"https://example.com"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := testIncoming(tt.msg).URLs(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("URLs = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIncomingIsViewOnce(t *testing.T) {
+	image := &waProto.Message{ImageMessage: &waProto.ImageMessage{}}
+	tests := []struct {
+		name string
+		evt  *events.Message
+		want bool
+	}{
+		{name: "plain image", evt: &events.Message{Message: image}, want: false},
+		{name: "view-once container", evt: &events.Message{Message: &waProto.Message{ViewOnceMessage: wrap(image)}}, want: true},
+		{name: "view-once v2 container", evt: &events.Message{Message: &waProto.Message{ViewOnceMessageV2: wrap(image)}}, want: true},
+		{name: "view-once v2 extension container", evt: &events.Message{Message: &waProto.Message{ViewOnceMessageV2Extension: wrap(&waProto.Message{AudioMessage: &waProto.AudioMessage{}})}}, want: true},
+		{name: "flag on the media", evt: &events.Message{Message: &waProto.Message{VideoMessage: &waProto.VideoMessage{ViewOnce: boolPtr(true)}}}, want: true},
+		{name: "flag on the event", evt: &events.Message{Message: image, IsViewOnceV2: true}, want: true},
+		{name: "text", evt: &events.Message{Message: &waProto.Message{Conversation: strPtr("hi")}}, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := NewIncomingMessage(tt.evt).IsViewOnce(); got != tt.want {
+				t.Errorf("IsViewOnce = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func boolPtr(b bool) *bool {
+	return &b
+}
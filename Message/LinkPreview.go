@@ -0,0 +1,293 @@
+package messages
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"path"
+	"strings"
+	"syscall"
+	"time"
+
+	utils "github.com/hacxk/easy-meow/Utils"
+
+	"golang.org/x/net/html"
+)
+
+// LinkPreview is the card WhatsApp shows above a text containing a link
+type LinkPreview struct {
+	// MatchedText is the link exactly as it appears in the text. It defaults to the first link in the text.
+	MatchedText  string
+	CanonicalURL string
+	Title        string
+	Description  string
+	// Thumbnail is a small JPEG, e.g. from utils.GetThumbnail
+	Thumbnail []byte
+}
+
+// HTTPDoer sends HTTP requests. *http.Client implements it; tests can pass a fake.
+type HTTPDoer interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+// PreviewFetcher loads link previews from the OpenGraph tags of web pages
+type PreviewFetcher struct {
+	// Client fetches pages and images. A nil client uses PublicHTTPClient, which only connects to public addresses.
+	Client HTTPDoer
+	// Timeout limits fetching the page and its image together
+	Timeout time.Duration
+	// MaxPageBytes is how much of a page is read looking for the tags in its head
+	MaxPageBytes int64
+	// MaxImageBytes is the largest preview image that is downloaded
+	MaxImageBytes int64
+	// UserAgent is sent with every request; some sites only serve OpenGraph tags to known crawlers
+	UserAgent string
+}
+
+// DefaultPreviewFetcher is used for automatic link previews unless another fetcher is given
+var DefaultPreviewFetcher = &PreviewFetcher{
+	Timeout:       10 * time.Second,
+	MaxPageBytes:  512 << 10,
+	MaxImageBytes: 5 << 20,
+	UserAgent:     "Mozilla/5.0 (compatible; easy-meow link preview)",
+}
+
+// ErrPrivateAddress is returned when a link preview would connect to a loopback, private, link-local, shared (CGNAT) or unspecified address
+var ErrPrivateAddress = errors.New("refusing to connect to a non-public address")
+
+// PublicHTTPClient is the default client of PreviewFetcher. Links in messages come from anyone, so it
+// refuses to connect to the bot's own machine or network, also when a public page redirects there or a
+// host name resolves there. It doesn't use a proxy, since the proxy would make the connections instead.
+var PublicHTTPClient = &http.Client{
+	Transport: &http.Transport{
+		DialContext: (&net.Dialer{
+			Timeout: 10 * time.Second,
+			Control: rejectPrivateAddress,
+		}).DialContext,
+		ForceAttemptHTTP2:   true,
+		TLSHandshakeTimeout: 10 * time.Second,
+		MaxIdleConns:        10,
+		IdleConnTimeout:     90 * time.Second,
+	},
+	CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		if len(via) >= 5 {
+			return errors.New("stopped after 5 redirects")
+		}
+		if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+			return fmt.Errorf("unsupported redirect scheme: %s", req.URL.Scheme)
+		}
+		return nil
+	},
+}
+
+// Ranges netip has no check for: 0.0.0.0/8 reaches the local host on some systems,
+// and 100.64.0.0/10 is carrier-grade NAT space that is often internal infrastructure
+var (
+	thisNetwork        = netip.MustParsePrefix("0.0.0.0/8")
+	sharedAddressSpace = netip.MustParsePrefix("100.64.0.0/10")
+)
+
+// rejectPrivateAddress checks the address a connection is about to be made to, after DNS resolution
+func rejectPrivateAddress(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip, err := netip.ParseAddr(host)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrPrivateAddress, host)
+	}
+	ip = ip.Unmap()
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified() ||
+		thisNetwork.Contains(ip) || sharedAddressSpace.Contains(ip) {
+		return fmt.Errorf("%w: %s", ErrPrivateAddress, ip)
+	}
+	return nil
+}
+
+// FirstURL returns the first link in text, or an empty string
+func FirstURL(text string) string {
+	match := urlPattern.FindString(text)
+	// Sentence punctuation right after a link isn't part of it
+	return strings.TrimRight(match, ".,!?;:)]}'")
+}
+
+// Fetch loads the preview of a link. A missing or broken image leaves the thumbnail empty instead of failing.
+func (f *PreviewFetcher) Fetch(ctx context.Context, link string) (*LinkPreview, error) {
+	if f.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, f.Timeout)
+		defer cancel()
+	}
+
+	target := link
+	if !strings.Contains(target, "://") {
+		target = "https://" + target
+	}
+	pageURL, err := url.Parse(target)
+	if err != nil {
+		return nil, fmt.Errorf("invalid link: %w", err)
+	}
+	if pageURL.Scheme != "http" && pageURL.Scheme != "https" {
+		return nil, fmt.Errorf("unsupported link scheme: %s", pageURL.Scheme)
+	}
+
+	resp, err := f.get(ctx, pageURL.String(), "text/html,application/xhtml+xml")
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch link: %w", err)
+	}
+	defer resp.Body.Close()
+	if mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type")); mediaType != "text/html" && mediaType != "application/xhtml+xml" {
+		return nil, fmt.Errorf("link is not a web page: %s", mediaType)
+	}
+	// Redirects change the base that relative image URLs resolve against
+	if resp.Request != nil && resp.Request.URL != nil {
+		pageURL = resp.Request.URL
+	}
+
+	body := io.Reader(resp.Body)
+	if f.MaxPageBytes > 0 {
+		body = io.LimitReader(resp.Body, f.MaxPageBytes)
+	}
+	tags := parseHead(body)
+	preview := &LinkPreview{
+		MatchedText:  link,
+		CanonicalURL: pageURL.String(),
+		Title:        firstNonEmpty(tags["og:title"], tags["twitter:title"], tags["title"]),
+		Description:  firstNonEmpty(tags["og:description"], tags["twitter:description"], tags["description"]),
+	}
+	if canonical := tags["og:url"]; canonical != "" {
+		if resolved, err := pageURL.Parse(canonical); err == nil {
+			preview.CanonicalURL = resolved.String()
+		}
+	}
+	if preview.Title == "" {
+		return nil, fmt.Errorf("page has no title")
+	}
+
+	if image := firstNonEmpty(tags["og:image:secure_url"], tags["og:image"], tags["twitter:image"]); image != "" {
+		if imageURL, err := pageURL.Parse(image); err == nil {
+			preview.Thumbnail, _ = f.thumbnail(ctx, imageURL.String())
+		}
+	}
+	return preview, nil
+}
+
+func (f *PreviewFetcher) get(ctx context.Context, target, accept string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", accept)
+	if f.UserAgent != "" {
+		req.Header.Set("User-Agent", f.UserAgent)
+	}
+	client := f.Client
+	if client == nil {
+		client = PublicHTTPClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		resp.Body.Close()
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return resp, nil
+}
+
+// thumbnail downloads the preview image and shrinks it to a JPEG thumbnail
+func (f *PreviewFetcher) thumbnail(ctx context.Context, imageURL string) ([]byte, error) {
+	resp, err := f.get(ctx, imageURL, "image/*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch preview image: %w", err)
+	}
+	defer resp.Body.Close()
+	if f.MaxImageBytes > 0 && resp.ContentLength > f.MaxImageBytes {
+		return nil, fmt.Errorf("preview image is too large: %d bytes", resp.ContentLength)
+	}
+
+	body := io.Reader(resp.Body)
+	if f.MaxImageBytes > 0 {
+		body = io.LimitReader(resp.Body, f.MaxImageBytes+1)
+	}
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read preview image: %w", err)
+	}
+	if f.MaxImageBytes > 0 && int64(len(data)) > f.MaxImageBytes {
+		return nil, fmt.Errorf("preview image is too large")
+	}
+	var fileName string
+	if parsed, err := url.Parse(imageURL); err == nil {
+		fileName = path.Base(parsed.Path)
+	}
+	media := utils.NewMedia(data, fileName, resp.Header.Get("Content-Type"))
+	return utils.GetMediaThumbnail(ctx, media)
+}
+
+// parseHead collects the title and the meta tags of an HTML page's head, keyed by property or name
+func parseHead(r io.Reader) map[string]string {
+	tags := make(map[string]string)
+	tokenizer := html.NewTokenizer(r)
+	inTitle := false
+	for {
+		switch tokenizer.Next() {
+		case html.ErrorToken:
+			return tags
+		case html.StartTagToken, html.SelfClosingTagToken:
+			token := tokenizer.Token()
+			switch token.Data {
+			case "body":
+				return tags
+			case "title":
+				inTitle = true
+			case "meta":
+				var key, content string
+				for _, attr := range token.Attr {
+					switch attr.Key {
+					case "property", "name":
+						if key == "" {
+							key = strings.ToLower(attr.Val)
+						}
+					case "content":
+						content = strings.TrimSpace(attr.Val)
+					}
+				}
+				// The first occurrence wins, like in WhatsApp's own previews
+				if key != "" && content != "" && tags[key] == "" {
+					tags[key] = content
+				}
+			}
+		case html.TextToken:
+			if inTitle && tags["title"] == "" {
+				tags["title"] = strings.TrimSpace(string(tokenizer.Text()))
+			}
+		case html.EndTagToken:
+			name, _ := tokenizer.TagName()
+			switch string(name) {
+			case "title":
+				inTitle = false
+			case "head":
+				return tags
+			}
+		}
+	}
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+	return ""
+}
@@ -0,0 +1,107 @@
+package messages
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRejectPrivateAddress(t *testing.T) {
+	tests := []struct {
+		address string
+		private bool
+	}{
+		{address: "127.0.0.1:80", private: true},
+		{address: "127.1.2.3:80", private: true},
+		{address: "[::1]:443", private: true},
+		{address: "10.0.0.5:80", private: true},
+		{address: "172.16.0.1:80", private: true},
+		{address: "192.168.1.1:80", private: true},
+		{address: "[fd00::1]:80", private: true},
+		{address: "169.254.169.254:80", private: true},
+		{address: "[fe80::1]:80", private: true},
+		{address: "0.0.0.0:80", private: true},
+		{address: "[::]:80", private: true},
+		{address: "[::ffff:127.0.0.1]:80", private: true},
+		{address: "[::ffff:10.1.2.3]:80", private: true},
+		{address: "224.0.0.1:80", private: true},
+		{address: "0.1.2.3:80", private: true},
+		{address: "100.64.0.1:80", private: true},
+		{address: "100.127.255.254:80", private: true},
+		{address: "[::ffff:100.100.100.100]:80", private: true},
+		{address: "100.63.255.255:80", private: false},
+		{address: "100.128.0.1:80", private: false},
+		{address: "93.184.215.14:443", private: false},
+		{address: "[2606:2800:21f:cb07:6820:80da:af6b:8b2c]:443", private: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.address, func(t *testing.T) {
+			err := rejectPrivateAddress("tcp", tt.address, nil)
+			if tt.private && !errors.Is(err, ErrPrivateAddress) {
+				t.Errorf("error = %v, want ErrPrivateAddress", err)
+			}
+			if !tt.private && err != nil {
+				t.Errorf("public address rejected: %v", err)
+			}
+		})
+	}
+}
+
+func TestFetchRefusesLocalServer(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, `<html><head><title>Internal</title></head></html>`)
+	}))
+	defer server.Close()
+
+	fetcher := *DefaultPreviewFetcher
+	if _, err := fetcher.Fetch(context.Background(), server.URL); !errors.Is(err, ErrPrivateAddress) {
+		t.Errorf("error = %v, want ErrPrivateAddress", err)
+	}
+
+	// A client of your own may reach local servers
+	fetcher.Client = server.Client()
+	preview, err := fetcher.Fetch(context.Background(), server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if preview.Title != "Internal" {
+		t.Errorf("title = %q", preview.Title)
+	}
+}
+
+func TestPreviewWithoutLink(t *testing.T) {
+	tests := []struct {
+		name    string
+		text    string
+		preview LinkPreview
+		want    string
+		err     bool
+	}{
+		{name: "no link", text: "no link here", preview: LinkPreview{Title: "Title"}, err: true},
+		{name: "link in text", text: "see www.example.com/page.", preview: LinkPreview{Title: "Title"}, want: "https://www.example.com/page"},
+		{name: "matched text given", text: "no link here", preview: LinkPreview{MatchedText: "http://example.com", Title: "Title"}, want: "http://example.com"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg, err := NewText(tt.text).Preview(tt.preview).Build(context.Background(), &fakeUploader{})
+			if tt.err {
+				if err == nil {
+					t.Fatalf("expected an error, got %v", msg)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := msg.GetExtendedTextMessage().GetCanonicalURL(); got != tt.want {
+				t.Errorf("canonical URL = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
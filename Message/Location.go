@@ -0,0 +1,190 @@
+package messages
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	utils "github.com/hacxk/easy-meow/Utils"
+
+	"go.mau.fi/whatsmeow"
+	waProto "go.mau.fi/whatsmeow/binary/proto"
+	"go.mau.fi/whatsmeow/types"
+	"google.golang.org/protobuf/proto"
+)
+
+// Location is a point on the map as sent in location and live location messages
+type Location struct {
+	Latitude  float64
+	Longitude float64
+	// Name and Address describe a place, e.g. a shop. They are only shown for static locations.
+	Name    string
+	Address string
+	URL     string
+	// Caption is the comment on a location or the text shown under a live location
+	Caption string
+
+	AccuracyMeters uint32
+	SpeedMps       float32
+	// Heading is the direction of travel in degrees clockwise from north
+	Heading uint32
+
+	// Live is set for live locations
+	Live bool
+	// Sequence counts the updates of a live location
+	Sequence int64
+	// TimeOffset is how long after sharing started a live location update was sent
+	TimeOffset time.Duration
+
+	// Thumbnail is the JPEG map preview. When sending without one, it is rendered with utils.MapThumbnail
+	// if utils.DefaultMapTiles has a tile server, except for live location updates.
+	Thumbnail []byte
+}
+
+// NewLocation starts a location message
+func NewLocation(location Location) *MessageBuilder {
+	return &MessageBuilder{kind: kindLocation, location: &location}
+}
+
+// NewLiveLocation starts a live location message. Send updates with SendLiveLocationTo.
+func NewLiveLocation(location Location) *MessageBuilder {
+	location.Live = true
+	return &MessageBuilder{kind: kindLocation, location: &location}
+}
+
+// buildLocation turns the builder's location into a location or live location message
+func (b *MessageBuilder) buildLocation(ctx context.Context, contextInfo *waProto.ContextInfo) (*waProto.Message, error) {
+	loc := b.location
+	if loc.Latitude < -90 || loc.Latitude > 90 || loc.Longitude < -180 || loc.Longitude > 180 {
+		return nil, fmt.Errorf("invalid coordinates: %f, %f", loc.Latitude, loc.Longitude)
+	}
+
+	thumbnail := loc.Thumbnail
+	// Maps are opt-in, and live location updates go without one rather than hitting the tile server for
+	// every position. A location without a map still shows as a pin, so a failed thumbnail isn't fatal.
+	if thumbnail == nil && loc.Sequence == 0 && utils.DefaultMapTiles.URL != "" {
+		thumbnail, _ = utils.MapThumbnail(ctx, loc.Latitude, loc.Longitude)
+	}
+
+	if loc.Live {
+		live := &waProto.LiveLocationMessage{
+			DegreesLatitude:  proto.Float64(loc.Latitude),
+			DegreesLongitude: proto.Float64(loc.Longitude),
+			JPEGThumbnail:    thumbnail,
+			ContextInfo:      contextInfo,
+		}
+		if loc.Caption != "" {
+			live.Caption = proto.String(loc.Caption)
+		}
+		if loc.AccuracyMeters > 0 {
+			live.AccuracyInMeters = proto.Uint32(loc.AccuracyMeters)
+		}
+		if loc.SpeedMps > 0 {
+			live.SpeedInMps = proto.Float32(loc.SpeedMps)
+		}
+		if loc.Heading > 0 {
+			live.DegreesClockwiseFromMagneticNorth = proto.Uint32(loc.Heading)
+		}
+		if loc.Sequence > 0 {
+			live.SequenceNumber = proto.Int64(loc.Sequence)
+			live.TimeOffset = proto.Uint32(uint32(loc.TimeOffset / time.Second))
+		}
+		return &waProto.Message{LiveLocationMessage: live}, nil
+	}
+
+	static := &waProto.LocationMessage{
+		DegreesLatitude:  proto.Float64(loc.Latitude),
+		DegreesLongitude: proto.Float64(loc.Longitude),
+		JPEGThumbnail:    thumbnail,
+		ContextInfo:      contextInfo,
+	}
+	if loc.Name != "" {
+		static.Name = proto.String(loc.Name)
+	}
+	if loc.Address != "" {
+		static.Address = proto.String(loc.Address)
+	}
+	if loc.URL != "" {
+		static.URL = proto.String(loc.URL)
+	}
+	if loc.Caption != "" {
+		static.Comment = proto.String(loc.Caption)
+	}
+	if loc.AccuracyMeters > 0 {
+		static.AccuracyInMeters = proto.Uint32(loc.AccuracyMeters)
+	}
+	return &waProto.Message{LocationMessage: static}, nil
+}
+
+// SendLiveLocationTo starts sharing a live location and sends every position received on updates
+// until the channel is closed or ctx ends. It blocks, so run it in its own goroutine.
+func SendLiveLocationTo(ctx context.Context, client *whatsmeow.Client, to types.JID, initial Location, updates <-chan Location) error {
+	return ShareLiveLocation(ctx, initial, updates, func(builder *MessageBuilder) error {
+		_, err := SendBuilt(ctx, client, to, builder)
+		return err
+	})
+}
+
+// ShareLiveLocation builds the live location messages for initial and each update and hands them to send,
+// until updates is closed or ctx ends. Updates keep the caption of the first message unless they set their own.
+func ShareLiveLocation(ctx context.Context, initial Location, updates <-chan Location, send func(builder *MessageBuilder) error) error {
+	if err := send(NewLiveLocation(initial)); err != nil {
+		return err
+	}
+
+	started := time.Now()
+	var sequence int64
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case update, ok := <-updates:
+			if !ok {
+				return nil
+			}
+			sequence++
+			update.Sequence = sequence
+			update.TimeOffset = time.Since(started)
+			if update.Caption == "" {
+				update.Caption = initial.Caption
+			}
+			if err := send(NewLiveLocation(update)); err != nil {
+				return fmt.Errorf("failed to send live location update %d: %w", sequence, err)
+			}
+		}
+	}
+}
+
+// Location returns the location of a location or live location message, or nil for other messages
+func (m *IncomingMessage) Location() *Location {
+	if static := m.Message.GetLocationMessage(); static != nil {
+		return &Location{
+			Latitude:       static.GetDegreesLatitude(),
+			Longitude:      static.GetDegreesLongitude(),
+			Name:           static.GetName(),
+			Address:        static.GetAddress(),
+			URL:            static.GetURL(),
+			Caption:        static.GetComment(),
+			AccuracyMeters: static.GetAccuracyInMeters(),
+			SpeedMps:       static.GetSpeedInMps(),
+			Heading:        static.GetDegreesClockwiseFromMagneticNorth(),
+			Live:           static.GetIsLive(),
+			Thumbnail:      static.GetJPEGThumbnail(),
+		}
+	}
+	if live := m.Message.GetLiveLocationMessage(); live != nil {
+		return &Location{
+			Latitude:       live.GetDegreesLatitude(),
+			Longitude:      live.GetDegreesLongitude(),
+			Caption:        live.GetCaption(),
+			AccuracyMeters: live.GetAccuracyInMeters(),
+			SpeedMps:       live.GetSpeedInMps(),
+			Heading:        live.GetDegreesClockwiseFromMagneticNorth(),
+			Live:           true,
+			Sequence:       live.GetSequenceNumber(),
+			TimeOffset:     time.Duration(live.GetTimeOffset()) * time.Second,
+			Thumbnail:      live.GetJPEGThumbnail(),
+		}
+	}
+	return nil
+}
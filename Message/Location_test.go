@@ -0,0 +1,169 @@
+package messages
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	waProto "go.mau.fi/whatsmeow/binary/proto"
+)
+
+func TestLocationRoundTrip(t *testing.T) {
+	tests := []struct {
+		name    string
+		builder *MessageBuilder
+		want    Location
+	}{
+		{
+			name: "static",
+			builder: NewLocation(Location{
+				Latitude: 52.52, Longitude: 13.405, Name: "Cafe", Address: "Main Street 1",
+				URL: "https://example.com", Caption: "Meet here", AccuracyMeters: 10, Thumbnail: []byte("jpeg"),
+			}),
+			want: Location{
+				Latitude: 52.52, Longitude: 13.405, Name: "Cafe", Address: "Main Street 1",
+				URL: "https://example.com", Caption: "Meet here", AccuracyMeters: 10, Thumbnail: []byte("jpeg"),
+			},
+		},
+		{
+			name:    "static without details",
+			builder: NewLocation(Location{Latitude: -33.8688, Longitude: 151.2093}),
+			want:    Location{Latitude: -33.8688, Longitude: 151.2093},
+		},
+		{
+			name: "live",
+			builder: NewLiveLocation(Location{
+				Latitude: 40.7128, Longitude: -74.006, Caption: "On my way", AccuracyMeters: 5,
+				SpeedMps: 1.5, Heading: 90, Thumbnail: []byte("jpeg"),
+			}),
+			want: Location{
+				Latitude: 40.7128, Longitude: -74.006, Caption: "On my way", AccuracyMeters: 5,
+				SpeedMps: 1.5, Heading: 90, Live: true, Thumbnail: []byte("jpeg"),
+			},
+		},
+		{
+			name:    "live update",
+			builder: NewLiveLocation(Location{Latitude: 1, Longitude: 2, Sequence: 3, TimeOffset: 45 * time.Second}),
+			want:    Location{Latitude: 1, Longitude: 2, Live: true, Sequence: 3, TimeOffset: 45 * time.Second},
+		},
+		{
+			name:    "edges of the map",
+			builder: NewLocation(Location{Latitude: -90, Longitude: 180}),
+			want:    Location{Latitude: -90, Longitude: 180},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg, err := tt.builder.Build(context.Background(), &fakeUploader{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := testIncoming(msg).Location()
+			if got == nil {
+				t.Fatalf("no location in %v", msg)
+			}
+			if !reflect.DeepEqual(*got, tt.want) {
+				t.Errorf("got %+v, want %+v", *got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLocationInvalidCoordinates(t *testing.T) {
+	tests := []struct {
+		name     string
+		location Location
+	}{
+		{name: "latitude too high", location: Location{Latitude: 90.1}},
+		{name: "latitude too low", location: Location{Latitude: -91}},
+		{name: "longitude too high", location: Location{Longitude: 180.5}},
+		{name: "longitude too low", location: Location{Longitude: -200}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, builder := range []*MessageBuilder{NewLocation(tt.location), NewLiveLocation(tt.location)} {
+				if msg, err := builder.Build(context.Background(), &fakeUploader{}); err == nil {
+					t.Errorf("expected an error, got %v", msg)
+				}
+			}
+		})
+	}
+}
+
+func TestIncomingLocationOtherMessage(t *testing.T) {
+	if location := testIncoming(&waProto.Message{Conversation: strPtr("hi")}).Location(); location != nil {
+		t.Errorf("text message has location %+v", location)
+	}
+}
+
+func TestShareLiveLocation(t *testing.T) {
+	updates := make(chan Location, 3)
+	updates <- Location{Latitude: 1.1, Longitude: 2.1}
+	updates <- Location{Latitude: 1.2, Longitude: 2.2, Caption: "Almost there"}
+	updates <- Location{Latitude: 1.3, Longitude: 2.3}
+	close(updates)
+
+	var sent []Location
+	err := ShareLiveLocation(context.Background(), Location{Latitude: 1, Longitude: 2, Caption: "On my way"}, updates, func(builder *MessageBuilder) error {
+		time.Sleep(time.Millisecond)
+		sent = append(sent, *builder.location)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	captions := []string{"On my way", "On my way", "Almost there", "On my way"}
+	if len(sent) != len(captions) {
+		t.Fatalf("sent %d messages, want %d", len(sent), len(captions))
+	}
+	for i, location := range sent {
+		if !location.Live {
+			t.Errorf("message %d isn't live", i)
+		}
+		if location.Sequence != int64(i) {
+			t.Errorf("message %d has sequence %d", i, location.Sequence)
+		}
+		if i > 0 && location.TimeOffset <= sent[i-1].TimeOffset {
+			t.Errorf("message %d time offset %v doesn't increase from %v", i, location.TimeOffset, sent[i-1].TimeOffset)
+		}
+		if location.Caption != captions[i] {
+			t.Errorf("message %d caption = %q, want %q", i, location.Caption, captions[i])
+		}
+	}
+}
+
+func TestShareLiveLocationStops(t *testing.T) {
+	errSend := errors.New("send failed")
+
+	t.Run("send error", func(t *testing.T) {
+		updates := make(chan Location, 1)
+		updates <- Location{Latitude: 1, Longitude: 2}
+		calls := 0
+		err := ShareLiveLocation(context.Background(), Location{}, updates, func(*MessageBuilder) error {
+			calls++
+			if calls == 2 {
+				return errSend
+			}
+			return nil
+		})
+		if !errors.Is(err, errSend) {
+			t.Errorf("error = %v, want %v", err, errSend)
+		}
+	})
+
+	t.Run("context ends", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		err := ShareLiveLocation(ctx, Location{}, make(chan Location), func(*MessageBuilder) error {
+			cancel()
+			return nil
+		})
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("error = %v, want context.Canceled", err)
+		}
+	})
+}
@@ -0,0 +1,46 @@
+package messages
+
+import (
+	"fmt"
+	"strings"
+
+	"go.mau.fi/whatsmeow/types"
+)
+
+// ParseRecipient turns a phone number or a JID string into a types.JID that can be used with the *To senders.
+// Phone numbers may contain a leading "+" and the usual separators (spaces, dashes, dots and parentheses).
+func ParseRecipient(recipient string) (types.JID, error) {
+	recipient = strings.TrimSpace(recipient)
+	if recipient == "" {
+		return types.JID{}, fmt.Errorf("empty recipient")
+	}
+
+	// Anything with a server part is already a JID
+	if strings.ContainsRune(recipient, '@') {
+		jid, err := types.ParseJID(recipient)
+		if err != nil {
+			return types.JID{}, fmt.Errorf("invalid recipient JID: %w", err)
+		}
+		return jid, nil
+	}
+
+	// Strip the formatting people usually put in phone numbers
+	number := strings.Map(func(r rune) rune {
+		switch r {
+		case '+', ' ', '-', '.', '(', ')':
+			return -1
+		}
+		return r
+	}, recipient)
+
+	if number == "" {
+		return types.JID{}, fmt.Errorf("invalid phone number: %q", recipient)
+	}
+	for _, r := range number {
+		if r < '0' || r > '9' {
+			return types.JID{}, fmt.Errorf("invalid phone number: %q", recipient)
+		}
+	}
+
+	return types.NewJID(number, types.DefaultUserServer), nil
+}
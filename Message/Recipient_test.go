@@ -0,0 +1,46 @@
+package messages
+
+import (
+	"testing"
+
+	"go.mau.fi/whatsmeow/types"
+)
+
+func TestParseRecipient(t *testing.T) {
+	tests := []struct {
+		name      string
+		recipient string
+		want      types.JID
+		err       bool
+	}{
+		{name: "formatted phone number", recipient: "+1 (555) 010-0100", want: types.NewJID("15550100100", types.DefaultUserServer)},
+		{name: "plain phone number", recipient: "15550100100", want: types.NewJID("15550100100", types.DefaultUserServer)},
+		{name: "dotted phone number with spaces around", recipient: "  49.30.1234567  ", want: types.NewJID("49301234567", types.DefaultUserServer)},
+		{name: "user JID", recipient: "15550100100@s.whatsapp.net", want: types.NewJID("15550100100", types.DefaultUserServer)},
+		{name: "device JID", recipient: "15550100100:12@s.whatsapp.net", want: types.NewADJID("15550100100", 0, 12)},
+		{name: "group JID", recipient: "120363025246125486@g.us", want: types.NewJID("120363025246125486", types.GroupServer)},
+		{name: "empty", recipient: "", err: true},
+		{name: "only spaces", recipient: "   ", err: true},
+		{name: "only separators", recipient: "+ ( ) -", err: true},
+		{name: "letters", recipient: "555-CALL-NOW", err: true},
+		{name: "invalid JID", recipient: "1555:x@s.whatsapp.net", err: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			jid, err := ParseRecipient(tt.recipient)
+			if tt.err {
+				if err == nil {
+					t.Fatalf("expected an error, got %v", jid)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if jid != tt.want {
+				t.Errorf("got %v, want %v", jid, tt.want)
+			}
+		})
+	}
+}
@@ -3,9 +3,6 @@ package messages
 import (
 	"context"
 	"fmt"
-	"net/http"
-	"os"
-	"strings"
 	"time"
 
 	utils "github.com/hacxk/easy-meow/Utils"
@@ -25,20 +22,16 @@ func btoi(b bool) int {
 	return 0
 }
 
-func SendTextMessage(client *whatsmeow.Client, evt *events.Message, message string) (*whatsmeow.SendResponse, error) {
-	msg := &waProto.Message{
-		Conversation: proto.String(message),
-	}
-	// Handle the error during SendMessage
-	sendResp, err := client.SendMessage(context.Background(), evt.Info.Chat, msg)
-	if err != nil {
-		return nil, fmt.Errorf("failed to send message: %v", err) // Format error message
-	}
+// SendTextMessageTo sends the same message as SendTextMessage to an arbitrary recipient
+func SendTextMessageTo(ctx context.Context, client *whatsmeow.Client, to types.JID, message string) (*whatsmeow.SendResponse, error) {
+	return SendBuilt(ctx, client, to, NewText(message))
+}
 
-	return &sendResp, nil // Return the response and nil error if successful
+func SendTextMessage(ctx context.Context, client *whatsmeow.Client, evt *events.Message, message string) (*whatsmeow.SendResponse, error) {
+	return SendTextMessageTo(ctx, client, evt.Info.Chat, message)
 }
 
-func ReactionMessage(client *whatsmeow.Client, evt *events.Message, reaction string) (*whatsmeow.SendResponse, error) {
+func ReactionMessage(ctx context.Context, client *whatsmeow.Client, evt *events.Message, reaction string) (*whatsmeow.SendResponse, error) {
 	chat := evt.Info.Chat.String()
 	isFromMe := evt.Info.IsFromMe
 	id := evt.Info.ID
@@ -56,15 +49,15 @@ func ReactionMessage(client *whatsmeow.Client, evt *events.Message, reaction str
 	}
 
 	// Handle the error during SendMessage
-	sendResp, err := client.SendMessage(context.Background(), evt.Info.Chat, msg)
+	sendResp, err := client.SendMessage(ctx, evt.Info.Chat, msg)
 	if err != nil {
-		return nil, fmt.Errorf("failed to send message: %v", err) // Format error message
+		return nil, fmt.Errorf("failed to send message: %w", err) // Format error message
 	}
 
 	return &sendResp, nil // Return the response and nil error if successful
 }
 
-func EditMessage(client *whatsmeow.Client, evt *events.Message, sentMessageID string, newMessageText string) (*whatsmeow.SendResponse, error) {
+func EditMessage(ctx context.Context, client *whatsmeow.Client, evt *events.Message, sentMessageID string, newMessageText string) (*whatsmeow.SendResponse, error) {
 	// Create the EditedMessage content
 	msg := &waProto.Message{
 		ProtocolMessage: &waProto.ProtocolMessage{
@@ -102,642 +95,153 @@ func EditMessage(client *whatsmeow.Client, evt *events.Message, sentMessageID st
 	}
 
 	// Send the edit request
-	sendResp, err := client.SendMessage(context.Background(), evt.Info.Chat, msg)
+	sendResp, err := client.SendMessage(ctx, evt.Info.Chat, msg)
 	if err != nil {
-		return nil, fmt.Errorf("failed to edit message: %v", err)
+		return nil, fmt.Errorf("failed to edit message: %w", err)
 	}
 
 	return &sendResp, nil
 }
 
-func ReplyToMessage(client *whatsmeow.Client, evt *events.Message, message string) (*whatsmeow.SendResponse, error) {
-	recipientJID := evt.Info.Sender.String()
-
-	// Split the JID to remove any device part
-	parts := strings.SplitN(recipientJID, ":", 2)
-	recipient := parts[0]
-
-	// Reconstruct the JID
-	jid, err := types.ParseJID(recipient + "@" + evt.Info.Sender.Server)
-	if err != nil {
-		return nil, fmt.Errorf("invalid recipient JID: %v", err)
-	}
-
-	quotedMessageID := evt.Info.ID
-
-	msg := &waProto.Message{
-		ExtendedTextMessage: &waProto.ExtendedTextMessage{
-			Text: proto.String(message),
-			ContextInfo: &waProto.ContextInfo{
-				StanzaID:      &quotedMessageID,
-				Participant:   proto.String(jid.String()),
-				QuotedMessage: evt.Message,
-			},
-		},
-	}
-
-	var sendResp whatsmeow.SendResponse
-	sendResp, err = client.SendMessage(context.Background(), evt.Info.Chat, msg)
-	if err != nil {
-		return nil, fmt.Errorf("failed to send message: %v", err)
-	}
-	return &sendResp, nil
+func ReplyToMessage(ctx context.Context, client *whatsmeow.Client, evt *events.Message, message string) (*whatsmeow.SendResponse, error) {
+	return SendBuilt(ctx, client, evt.Info.Chat, NewText(message).ReplyTo(evt))
 }
 
-func SendImageMessage(client *whatsmeow.Client, evt *events.Message, imageFile string, caption string) (*whatsmeow.SendResponse, error) {
-	// Upload Image
-	data, err := os.ReadFile(imageFile)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read image file: %v", err)
-	}
-
-	uploaded, err := client.Upload(context.Background(), data, whatsmeow.MediaImage)
-	if err != nil {
-		return nil, fmt.Errorf("failed to upload image: %v", err)
-	}
-
-	// Determine MIME type
-	mimeType := http.DetectContentType(data)
-	if mimeType == "" {
-		mimeType = "image/jpeg" // Default to JPEG if detection fails
-	}
-
-	// Create Image Message
-	msg := &waProto.Message{
-		ImageMessage: &waProto.ImageMessage{
-			Mimetype:      proto.String(mimeType),
-			StaticURL:     proto.String(uploaded.URL),
-			DirectPath:    proto.String(uploaded.DirectPath),
-			MediaKey:      uploaded.MediaKey,
-			FileEncSHA256: uploaded.FileEncSHA256,
-			FileSHA256:    uploaded.FileSHA256,
-			FileLength:    proto.Uint64(uploaded.FileLength),
-		},
-	}
-
-	if caption != "" {
-		msg.ImageMessage.Caption = proto.String(caption)
-	}
-
-	// Get and Set Thumbnail (With Error Handling)
-	thumbnailBytes, err := utils.GetThumbnail(imageFile)
-	if err == nil { // Only set thumbnail if no errors occurred
-		msg.ImageMessage.JPEGThumbnail = thumbnailBytes
-	}
-
-	// Send Message
-	sendResp, err := client.SendMessage(context.Background(), evt.Info.Chat, msg)
-	if err != nil {
-		return nil, fmt.Errorf("failed to send image message: %v", err)
-	}
-	return &sendResp, nil
+// SendImageMessageTo sends the same message as SendImageMessage to an arbitrary recipient
+func SendImageMessageTo(ctx context.Context, client *whatsmeow.Client, to types.JID, image utils.MediaSource, caption string) (*whatsmeow.SendResponse, error) {
+	return SendBuilt(ctx, client, to, NewImage(image).Caption(caption))
 }
 
-func SendImageMessageReply(client *whatsmeow.Client, evt *events.Message, imageFile string, caption string) (*whatsmeow.SendResponse, error) {
-	recipientJID := evt.Info.Sender.String()
-
-	// Split the JID to remove any device part
-	parts := strings.SplitN(recipientJID, ":", 2)
-	recipient := parts[0]
-
-	// Reconstruct the JID
-	jid, err := types.ParseJID(recipient + "@" + evt.Info.Sender.Server)
-	if err != nil {
-		return nil, fmt.Errorf("invalid recipient JID: %v", err)
-	}
-
-	// Read the image file
-	data, err := os.ReadFile(imageFile)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read image file: %v", err)
-	}
-
-	// Upload the image to WhatsApp
-	uploaded, err := client.Upload(context.Background(), data, whatsmeow.MediaImage)
-	if err != nil {
-		return nil, fmt.Errorf("failed to upload image: %v", err)
-	}
-
-	// Create the image message
-	msg := &waProto.Message{
-		ImageMessage: &waProto.ImageMessage{
-			Mimetype:      proto.String(http.DetectContentType(data)),
-			StaticURL:     proto.String(uploaded.URL),
-			DirectPath:    proto.String(uploaded.DirectPath),
-			MediaKey:      uploaded.MediaKey,
-			FileEncSHA256: uploaded.FileEncSHA256,
-			FileSHA256:    uploaded.FileSHA256,
-			FileLength:    proto.Uint64(uploaded.FileLength),
-			ContextInfo: &waProto.ContextInfo{
-				QuotedMessage: evt.Message,
-				StanzaID:      proto.String(evt.Info.ID),
-				Participant:   proto.String(jid.String()),
-			},
-		},
-	}
-
-	// Add caption if provided
-	if caption != "" {
-		msg.ImageMessage.Caption = proto.String(caption)
-	}
-
-	// Get and Set Thumbnail (With Error Handling)
-	thumbnailBytes, err := utils.GetThumbnail(imageFile)
-	if err == nil { // Only set thumbnail if no errors occurred
-		msg.ImageMessage.JPEGThumbnail = thumbnailBytes
-	}
-
-	var sendResp whatsmeow.SendResponse
-	sendResp, err = client.SendMessage(context.Background(), evt.Info.Chat, msg)
-	if err != nil {
-		return nil, fmt.Errorf("failed to send message: %v", err)
-	}
-	return &sendResp, nil
+func SendImageMessage(ctx context.Context, client *whatsmeow.Client, evt *events.Message, image utils.MediaSource, caption string) (*whatsmeow.SendResponse, error) {
+	return SendImageMessageTo(ctx, client, evt.Info.Chat, image, caption)
 }
 
-func SendVideoMessage(client *whatsmeow.Client, evt *events.Message, videoFile string, caption string) (*whatsmeow.SendResponse, error) {
-	// Read the video file
-	data, err := os.ReadFile(videoFile)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read video file: %v", err)
-	}
-
-	// Upload the video to WhatsApp
-	uploaded, err := client.Upload(context.Background(), data, whatsmeow.MediaVideo)
-	if err != nil {
-		return nil, fmt.Errorf("failed to upload video: %v", err)
-	}
-
-	// Create the video message
-	msg := &waProto.Message{
-		VideoMessage: &waProto.VideoMessage{
-			URL:           proto.String(uploaded.URL),
-			DirectPath:    proto.String(uploaded.DirectPath),
-			MediaKey:      uploaded.MediaKey,
-			FileEncSHA256: uploaded.FileEncSHA256,
-			FileSHA256:    uploaded.FileSHA256,
-			FileLength:    proto.Uint64(uploaded.FileLength),
-			Mimetype:      proto.String(http.DetectContentType(data)),
-			Caption:       proto.String(caption),
-		},
-	}
-
-	// Get and Set Thumbnail (With Error Handling)
-	thumbnailBytes, err := utils.GetThumbnail(videoFile)
-	if err == nil { // Only set thumbnail if no errors occurred
-		msg.VideoMessage.JPEGThumbnail = thumbnailBytes
-	}
-
-	var sendResp whatsmeow.SendResponse
-	sendResp, err = client.SendMessage(context.Background(), evt.Info.Chat, msg)
-	if err != nil {
-		return nil, fmt.Errorf("failed to send message: %v", err)
-	}
-	return &sendResp, nil
+func SendImageMessageReply(ctx context.Context, client *whatsmeow.Client, evt *events.Message, image utils.MediaSource, caption string) (*whatsmeow.SendResponse, error) {
+	return SendBuilt(ctx, client, evt.Info.Chat, NewImage(image).Caption(caption).ReplyTo(evt))
 }
 
-func SendVideoMessageReply(client *whatsmeow.Client, evt *events.Message, videoFile string, caption string) (*whatsmeow.SendResponse, error) {
-	recipientJID := evt.Info.Sender.String()
-	// Split the JID to remove any device part
-	parts := strings.SplitN(recipientJID, ":", 2)
-	recipient := parts[0]
-	// Reconstruct the JID
-	jid, err := types.ParseJID(recipient + "@" + evt.Info.Sender.Server)
-	if err != nil {
-		return nil, fmt.Errorf("invalid recipient JID: %v", err)
-	}
-
-	// Read the video file
-	data, err := os.ReadFile(videoFile)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read video file: %v", err)
-	}
-
-	// Upload the video to WhatsApp
-	uploaded, err := client.Upload(context.Background(), data, whatsmeow.MediaVideo)
-	if err != nil {
-		return nil, fmt.Errorf("failed to upload video: %v", err)
-	}
-
-	// Create the video message
-	msg := &waProto.Message{
-		VideoMessage: &waProto.VideoMessage{
-			URL:           proto.String(uploaded.URL),
-			DirectPath:    proto.String(uploaded.DirectPath),
-			MediaKey:      uploaded.MediaKey,
-			FileEncSHA256: uploaded.FileEncSHA256,
-			FileSHA256:    uploaded.FileSHA256,
-			FileLength:    proto.Uint64(uploaded.FileLength),
-			Mimetype:      proto.String(http.DetectContentType(data)),
-			Caption:       proto.String(caption),
-			ContextInfo: &waProto.ContextInfo{
-				QuotedMessage: evt.Message,
-				StanzaID:      proto.String(evt.Info.ID),
-				Participant:   proto.String(jid.String()),
-			},
-		},
-	}
-
-	// Get and Set Thumbnail (With Error Handling)
-	thumbnailBytes, err := utils.GetThumbnail(videoFile)
-	if err == nil { // Only set thumbnail if no errors occurred
-		msg.VideoMessage.JPEGThumbnail = thumbnailBytes
-	}
-
-	var sendResp whatsmeow.SendResponse
-	sendResp, err = client.SendMessage(context.Background(), evt.Info.Chat, msg)
-	if err != nil {
-		return nil, fmt.Errorf("failed to send message: %v", err)
-	}
-	return &sendResp, nil
+// SendVideoMessageTo sends the same message as SendVideoMessage to an arbitrary recipient
+func SendVideoMessageTo(ctx context.Context, client *whatsmeow.Client, to types.JID, video utils.MediaSource, caption string) (*whatsmeow.SendResponse, error) {
+	return SendBuilt(ctx, client, to, NewVideo(video).Caption(caption))
 }
 
-func SendAudioMessage(client *whatsmeow.Client, evt *events.Message, audioFile string, ptt bool) (*whatsmeow.SendResponse, error) {
-	data, err := os.ReadFile(audioFile)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read audio file: %v", err)
-	}
-
-	uploaded, err := client.Upload(context.Background(), data, whatsmeow.MediaAudio)
-	if err != nil {
-		return nil, fmt.Errorf("failed to upload audio: %v", err)
-	}
-
-	msg := &waProto.Message{
-		AudioMessage: &waProto.AudioMessage{
-			URL:           proto.String(uploaded.URL),
-			DirectPath:    proto.String(uploaded.DirectPath),
-			MediaKey:      uploaded.MediaKey,
-			FileEncSHA256: uploaded.FileEncSHA256,
-			FileSHA256:    uploaded.FileSHA256,
-			FileLength:    proto.Uint64(uploaded.FileLength),
-			Mimetype:      proto.String(http.DetectContentType(data)),
-			PTT:           proto.Bool(ptt),
-		},
-	}
-
-	var sendResp whatsmeow.SendResponse
-	sendResp, err = client.SendMessage(context.Background(), evt.Info.Chat, msg)
-	if err != nil {
-		return nil, fmt.Errorf("failed to send message: %v", err)
-	}
-	return &sendResp, nil
+func SendVideoMessage(ctx context.Context, client *whatsmeow.Client, evt *events.Message, video utils.MediaSource, caption string) (*whatsmeow.SendResponse, error) {
+	return SendVideoMessageTo(ctx, client, evt.Info.Chat, video, caption)
 }
 
-func SendAudioMessageReply(client *whatsmeow.Client, evt *events.Message, audioFile string, ptt bool) (*whatsmeow.SendResponse, error) {
-	recipientJID := evt.Info.Sender.String()
-	parts := strings.SplitN(recipientJID, ":", 2)
-	recipient := parts[0]
-	jid, err := types.ParseJID(recipient + "@" + evt.Info.Sender.Server)
-	if err != nil {
-		return nil, fmt.Errorf("invalid recipient JID: %v", err)
-	}
-
-	data, err := os.ReadFile(audioFile)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read audio file: %v", err)
-	}
-
-	uploaded, err := client.Upload(context.Background(), data, whatsmeow.MediaAudio)
-	if err != nil {
-		return nil, fmt.Errorf("failed to upload audio: %v", err)
-	}
-
-	msg := &waProto.Message{
-		AudioMessage: &waProto.AudioMessage{
-			URL:           proto.String(uploaded.URL),
-			DirectPath:    proto.String(uploaded.DirectPath),
-			MediaKey:      uploaded.MediaKey,
-			FileEncSHA256: uploaded.FileEncSHA256,
-			FileSHA256:    uploaded.FileSHA256,
-			FileLength:    proto.Uint64(uploaded.FileLength),
-			Mimetype:      proto.String(http.DetectContentType(data)),
-			PTT:           proto.Bool(ptt),
-			ContextInfo: &waProto.ContextInfo{
-				QuotedMessage: evt.Message,
-				StanzaID:      proto.String(evt.Info.ID),
-				Participant:   proto.String(jid.String()),
-			},
-		},
-	}
-
-	var sendResp whatsmeow.SendResponse
-	sendResp, err = client.SendMessage(context.Background(), evt.Info.Chat, msg)
-	if err != nil {
-		return nil, fmt.Errorf("failed to send message: %v", err)
-	}
-	return &sendResp, nil
+func SendVideoMessageReply(ctx context.Context, client *whatsmeow.Client, evt *events.Message, video utils.MediaSource, caption string) (*whatsmeow.SendResponse, error) {
+	return SendBuilt(ctx, client, evt.Info.Chat, NewVideo(video).Caption(caption).ReplyTo(evt))
 }
 
-func SendDocumentMessage(client *whatsmeow.Client, evt *events.Message, documentFile string, fileName string, caption string) (*whatsmeow.SendResponse, error) {
-	data, err := os.ReadFile(documentFile)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read document file: %v", err)
-	}
-
-	uploaded, err := client.Upload(context.Background(), data, whatsmeow.MediaDocument)
-	if err != nil {
-		return nil, fmt.Errorf("failed to upload document: %v", err)
-	}
-
-	msg := &waProto.Message{
-		DocumentMessage: &waProto.DocumentMessage{
-			URL:           proto.String(uploaded.URL),
-			DirectPath:    proto.String(uploaded.DirectPath),
-			MediaKey:      uploaded.MediaKey,
-			FileEncSHA256: uploaded.FileEncSHA256,
-			FileSHA256:    uploaded.FileSHA256,
-			FileLength:    proto.Uint64(uploaded.FileLength),
-			Mimetype:      proto.String(http.DetectContentType(data)),
-			FileName:      proto.String(fileName),
-			Caption:       proto.String(caption),
-		},
-	}
-
-	var sendResp whatsmeow.SendResponse
-	sendResp, err = client.SendMessage(context.Background(), evt.Info.Chat, msg)
-	if err != nil {
-		return nil, fmt.Errorf("failed to send message: %v", err)
-	}
-	return &sendResp, nil
+// SendAudioMessageTo sends the same message as SendAudioMessage to an arbitrary recipient
+func SendAudioMessageTo(ctx context.Context, client *whatsmeow.Client, to types.JID, audio utils.MediaSource, ptt bool) (*whatsmeow.SendResponse, error) {
+	return SendBuilt(ctx, client, to, NewAudioPTT(audio, ptt))
 }
 
-func SendDocumentMessageReply(client *whatsmeow.Client, evt *events.Message, documentFile string, fileName string, caption string) (*whatsmeow.SendResponse, error) {
-	recipientJID := evt.Info.Sender.String()
-	parts := strings.SplitN(recipientJID, ":", 2)
-	recipient := parts[0]
-	jid, err := types.ParseJID(recipient + "@" + evt.Info.Sender.Server)
-	if err != nil {
-		return nil, fmt.Errorf("invalid recipient JID: %v", err)
-	}
-
-	data, err := os.ReadFile(documentFile)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read document file: %v", err)
-	}
-
-	uploaded, err := client.Upload(context.Background(), data, whatsmeow.MediaDocument)
-	if err != nil {
-		return nil, fmt.Errorf("failed to upload document: %v", err)
-	}
-
-	msg := &waProto.Message{
-		DocumentMessage: &waProto.DocumentMessage{
-			URL:           proto.String(uploaded.URL),
-			DirectPath:    proto.String(uploaded.DirectPath),
-			MediaKey:      uploaded.MediaKey,
-			FileEncSHA256: uploaded.FileEncSHA256,
-			FileSHA256:    uploaded.FileSHA256,
-			FileLength:    proto.Uint64(uploaded.FileLength),
-			Mimetype:      proto.String(http.DetectContentType(data)),
-			FileName:      proto.String(fileName),
-			Caption:       proto.String(caption),
-			ContextInfo: &waProto.ContextInfo{
-				QuotedMessage: evt.Message,
-				StanzaID:      proto.String(evt.Info.ID),
-				Participant:   proto.String(jid.String()),
-			},
-		},
-	}
-
-	var sendResp whatsmeow.SendResponse
-	sendResp, err = client.SendMessage(context.Background(), evt.Info.Chat, msg)
-	if err != nil {
-		return nil, fmt.Errorf("failed to send message: %v", err)
-	}
-	return &sendResp, nil
+func SendAudioMessage(ctx context.Context, client *whatsmeow.Client, evt *events.Message, audio utils.MediaSource, ptt bool) (*whatsmeow.SendResponse, error) {
+	return SendAudioMessageTo(ctx, client, evt.Info.Chat, audio, ptt)
 }
 
-func SendStickerMessage(client *whatsmeow.Client, evt *events.Message, stickerFile string) (*whatsmeow.SendResponse, error) {
-	data, err := os.ReadFile(stickerFile)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read sticker file: %v", err)
-	}
+func SendAudioMessageReply(ctx context.Context, client *whatsmeow.Client, evt *events.Message, audio utils.MediaSource, ptt bool) (*whatsmeow.SendResponse, error) {
+	return SendBuilt(ctx, client, evt.Info.Chat, NewAudioPTT(audio, ptt).ReplyTo(evt))
+}
 
-	uploaded, err := client.Upload(context.Background(), data, whatsmeow.MediaImage)
-	if err != nil {
-		return nil, fmt.Errorf("failed to upload sticker: %v", err)
-	}
+// SendDocumentMessageTo sends the same message as SendDocumentMessage to an arbitrary recipient
+func SendDocumentMessageTo(ctx context.Context, client *whatsmeow.Client, to types.JID, document utils.MediaSource, fileName string, caption string) (*whatsmeow.SendResponse, error) {
+	return SendBuilt(ctx, client, to, NewDocument(document).FileName(fileName).Caption(caption))
+}
 
-	msg := &waProto.Message{
-		StickerMessage: &waProto.StickerMessage{
-			URL:           proto.String(uploaded.URL),
-			DirectPath:    proto.String(uploaded.DirectPath),
-			MediaKey:      uploaded.MediaKey,
-			FileEncSHA256: uploaded.FileEncSHA256,
-			FileSHA256:    uploaded.FileSHA256,
-			FileLength:    proto.Uint64(uploaded.FileLength),
-			Mimetype:      proto.String("image/webp"),
-		},
-	}
+func SendDocumentMessage(ctx context.Context, client *whatsmeow.Client, evt *events.Message, document utils.MediaSource, fileName string, caption string) (*whatsmeow.SendResponse, error) {
+	return SendDocumentMessageTo(ctx, client, evt.Info.Chat, document, fileName, caption)
+}
 
-	var sendResp whatsmeow.SendResponse
-	sendResp, err = client.SendMessage(context.Background(), evt.Info.Chat, msg)
-	if err != nil {
-		return nil, fmt.Errorf("failed to send message: %v", err)
-	}
-	return &sendResp, nil
+func SendDocumentMessageReply(ctx context.Context, client *whatsmeow.Client, evt *events.Message, document utils.MediaSource, fileName string, caption string) (*whatsmeow.SendResponse, error) {
+	return SendBuilt(ctx, client, evt.Info.Chat, NewDocument(document).FileName(fileName).Caption(caption).ReplyTo(evt))
 }
 
-func SendGifMessage(client *whatsmeow.Client, evt *events.Message, gifFile string, caption string) (*whatsmeow.SendResponse, error) {
-	data, err := os.ReadFile(gifFile)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read GIF file: %v", err)
-	}
+// SendStickerMessageTo sends the same message as SendStickerMessage to an arbitrary recipient
+func SendStickerMessageTo(ctx context.Context, client *whatsmeow.Client, to types.JID, sticker utils.MediaSource) (*whatsmeow.SendResponse, error) {
+	return SendBuilt(ctx, client, to, NewSticker(sticker))
+}
 
-	uploaded, err := client.Upload(context.Background(), data, whatsmeow.MediaVideo)
-	if err != nil {
-		return nil, fmt.Errorf("failed to upload GIF: %v", err)
-	}
+func SendStickerMessage(ctx context.Context, client *whatsmeow.Client, evt *events.Message, sticker utils.MediaSource) (*whatsmeow.SendResponse, error) {
+	return SendStickerMessageTo(ctx, client, evt.Info.Chat, sticker)
+}
 
-	msg := &waProto.Message{
-		VideoMessage: &waProto.VideoMessage{
-			URL:           proto.String(uploaded.URL),
-			DirectPath:    proto.String(uploaded.DirectPath),
-			MediaKey:      uploaded.MediaKey,
-			FileEncSHA256: uploaded.FileEncSHA256,
-			FileSHA256:    uploaded.FileSHA256,
-			FileLength:    proto.Uint64(uploaded.FileLength),
-			Mimetype:      proto.String("video/mp4"),
-			Caption:       proto.String(caption),
-			GifPlayback:   proto.Bool(true),
-		},
-	}
+// SendGifMessageTo sends the same message as SendGifMessage to an arbitrary recipient
+func SendGifMessageTo(ctx context.Context, client *whatsmeow.Client, to types.JID, gif utils.MediaSource, caption string) (*whatsmeow.SendResponse, error) {
+	return SendBuilt(ctx, client, to, NewGif(gif).Caption(caption))
+}
 
-	// Get and Set Thumbnail (With Error Handling)
-	thumbnailBytes, err := utils.GetThumbnail(gifFile)
-	if err == nil { // Only set thumbnail if no errors occurred
-		msg.VideoMessage.JPEGThumbnail = thumbnailBytes
-	}
+func SendGifMessage(ctx context.Context, client *whatsmeow.Client, evt *events.Message, gif utils.MediaSource, caption string) (*whatsmeow.SendResponse, error) {
+	return SendGifMessageTo(ctx, client, evt.Info.Chat, gif, caption)
+}
 
-	var sendResp whatsmeow.SendResponse
-	sendResp, err = client.SendMessage(context.Background(), evt.Info.Chat, msg)
-	if err != nil {
-		return nil, fmt.Errorf("failed to send message: %v", err)
-	}
-	return &sendResp, nil
+func SendStickerMessageReply(ctx context.Context, client *whatsmeow.Client, evt *events.Message, sticker utils.MediaSource) (*whatsmeow.SendResponse, error) {
+	return SendBuilt(ctx, client, evt.Info.Chat, NewSticker(sticker).ReplyTo(evt))
 }
 
-func SendStickerMessageReply(client *whatsmeow.Client, evt *events.Message, stickerFile string) (*whatsmeow.SendResponse, error) {
-	recipientJID := evt.Info.Sender.String()
-	parts := strings.SplitN(recipientJID, ":", 2)
-	recipient := parts[0]
-	jid, err := types.ParseJID(recipient + "@" + evt.Info.Sender.Server)
-	if err != nil {
-		return nil, fmt.Errorf("invalid recipient JID: %v", err)
-	}
-	data, err := os.ReadFile(stickerFile)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read sticker file: %v", err)
-	}
-	uploaded, err := client.Upload(context.Background(), data, whatsmeow.MediaImage)
-	if err != nil {
-		return nil, fmt.Errorf("failed to upload sticker: %v", err)
-	}
-	msg := &waProto.Message{
-		StickerMessage: &waProto.StickerMessage{
-			URL:           proto.String(uploaded.URL),
-			DirectPath:    proto.String(uploaded.DirectPath),
-			MediaKey:      uploaded.MediaKey,
-			FileEncSHA256: uploaded.FileEncSHA256,
-			FileSHA256:    uploaded.FileSHA256,
-			FileLength:    proto.Uint64(uploaded.FileLength),
-			Mimetype:      proto.String("image/webp"),
-			ContextInfo: &waProto.ContextInfo{
-				QuotedMessage: evt.Message,
-				StanzaID:      proto.String(evt.Info.ID),
-				Participant:   proto.String(jid.String()),
-			},
-		},
-	}
+func SendGifMessageReply(ctx context.Context, client *whatsmeow.Client, evt *events.Message, gif utils.MediaSource, caption string) (*whatsmeow.SendResponse, error) {
+	return SendBuilt(ctx, client, evt.Info.Chat, NewGif(gif).Caption(caption).ReplyTo(evt))
+}
 
-	var sendResp whatsmeow.SendResponse
-	sendResp, err = client.SendMessage(context.Background(), evt.Info.Chat, msg)
-	if err != nil {
-		return nil, fmt.Errorf("failed to send message: %v", err)
-	}
-	return &sendResp, nil
+// SendMentionMessageTo sends the same message as SendMentionMessage to an arbitrary recipient
+func SendMentionMessageTo(ctx context.Context, client *whatsmeow.Client, to types.JID, message string, mentions []string) (*whatsmeow.SendResponse, error) {
+	return SendBuilt(ctx, client, to, NewText(message).Mention(mentions...))
 }
 
-func SendGifMessageReply(client *whatsmeow.Client, evt *events.Message, gifFile string, caption string) (*whatsmeow.SendResponse, error) {
-	recipientJID := evt.Info.Sender.String()
-	parts := strings.SplitN(recipientJID, ":", 2)
-	recipient := parts[0]
-	jid, err := types.ParseJID(recipient + "@" + evt.Info.Sender.Server)
-	if err != nil {
-		return nil, fmt.Errorf("invalid recipient JID: %v", err)
-	}
-	data, err := os.ReadFile(gifFile)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read GIF file: %v", err)
-	}
-	uploaded, err := client.Upload(context.Background(), data, whatsmeow.MediaVideo)
-	if err != nil {
-		return nil, fmt.Errorf("failed to upload GIF: %v", err)
-	}
-	msg := &waProto.Message{
-		VideoMessage: &waProto.VideoMessage{
-			URL:           proto.String(uploaded.URL),
-			DirectPath:    proto.String(uploaded.DirectPath),
-			MediaKey:      uploaded.MediaKey,
-			FileEncSHA256: uploaded.FileEncSHA256,
-			FileSHA256:    uploaded.FileSHA256,
-			FileLength:    proto.Uint64(uploaded.FileLength),
-			Mimetype:      proto.String("video/mp4"),
-			Caption:       proto.String(caption),
-			GifPlayback:   proto.Bool(true),
-			ContextInfo: &waProto.ContextInfo{
-				QuotedMessage: evt.Message,
-				StanzaID:      proto.String(evt.Info.ID),
-				Participant:   proto.String(jid.String()),
-			},
-		},
-	}
+func SendMentionMessage(ctx context.Context, client *whatsmeow.Client, evt *events.Message, message string, mentions []string) (*whatsmeow.SendResponse, error) {
+	return SendMentionMessageTo(ctx, client, evt.Info.Chat, message, mentions)
+}
 
-	// Get and Set Thumbnail (With Error Handling)
-	thumbnailBytes, err := utils.GetThumbnail(gifFile)
-	if err == nil { // Only set thumbnail if no errors occurred
-		msg.VideoMessage.JPEGThumbnail = thumbnailBytes
-	}
+// SendPhoneNumberMessageTo sends the same message as SendPhoneNumberMessage to an arbitrary recipient
+//
+// Deprecated: recipients don't get a tappable contact. Use SendContactMessageTo with NewContact(name).AddPhone(number).
+func SendPhoneNumberMessageTo(ctx context.Context, client *whatsmeow.Client, to types.JID, phoneNumber string, message string) (*whatsmeow.SendResponse, error) {
+	return SendBuilt(ctx, client, to, NewPhoneNumberText(phoneNumber, message))
+}
 
-	var sendResp whatsmeow.SendResponse
-	sendResp, err = client.SendMessage(context.Background(), evt.Info.Chat, msg)
-	if err != nil {
-		return nil, fmt.Errorf("failed to send message: %v", err)
-	}
-	return &sendResp, nil
+// Deprecated: use SendContactMessage, which sends a real contact card.
+func SendPhoneNumberMessage(ctx context.Context, client *whatsmeow.Client, evt *events.Message, phoneNumber string, message string) (*whatsmeow.SendResponse, error) {
+	return SendPhoneNumberMessageTo(ctx, client, evt.Info.Chat, phoneNumber, message)
 }
 
-func SendMentionMessage(client *whatsmeow.Client, evt *events.Message, message string, mentions []string) (*whatsmeow.SendResponse, error) {
-	mentionedJIDs := make([]string, len(mentions))
-	for i, mention := range mentions {
-		mentionedJID, err := types.ParseJID(mention)
-		if err != nil {
-			return nil, fmt.Errorf("invalid mentioned JID: %v", err)
-		}
-		mentionedJIDs[i] = mentionedJID.String()
-	}
+// SendContactMessageTo sends one contact card, or a list of cards when several contacts are given
+func SendContactMessageTo(ctx context.Context, client *whatsmeow.Client, to types.JID, contacts ...*Contact) (*whatsmeow.SendResponse, error) {
+	return SendBuilt(ctx, client, to, NewContacts(contacts...))
+}
 
-	msg := &waProto.Message{
-		ExtendedTextMessage: &waProto.ExtendedTextMessage{
-			Text: proto.String(message),
-			ContextInfo: &waProto.ContextInfo{
-				MentionedJID: mentionedJIDs,
-			},
-		},
-	}
+func SendContactMessage(ctx context.Context, client *whatsmeow.Client, evt *events.Message, contacts ...*Contact) (*whatsmeow.SendResponse, error) {
+	return SendContactMessageTo(ctx, client, evt.Info.Chat, contacts...)
+}
 
-	var sendResp whatsmeow.SendResponse
-	sendResp, err := client.SendMessage(context.Background(), evt.Info.Chat, msg)
-	if err != nil {
-		return nil, fmt.Errorf("failed to send message: %v", err)
-	}
-	return &sendResp, nil
+func SendContactReply(ctx context.Context, client *whatsmeow.Client, evt *events.Message, contacts ...*Contact) (*whatsmeow.SendResponse, error) {
+	return SendBuilt(ctx, client, evt.Info.Chat, NewContacts(contacts...).ReplyTo(evt))
 }
 
-func SendPhoneNumberMessage(client *whatsmeow.Client, evt *events.Message, phoneNumber string, message string) (*whatsmeow.SendResponse, error) {
-	msg := &waProto.Message{
-		ExtendedTextMessage: &waProto.ExtendedTextMessage{
-			Text: proto.String(message),
-			ContextInfo: &waProto.ContextInfo{
-				Participant: proto.String(phoneNumber + "@s.whatsapp.net"),
-			},
-		},
-	}
+// SendLocationMessageTo sends a location pin with a map preview
+func SendLocationMessageTo(ctx context.Context, client *whatsmeow.Client, to types.JID, location Location) (*whatsmeow.SendResponse, error) {
+	return SendBuilt(ctx, client, to, NewLocation(location))
+}
 
-	var sendResp whatsmeow.SendResponse
-	sendResp, err := client.SendMessage(context.Background(), evt.Info.Chat, msg)
-	if err != nil {
-		return nil, fmt.Errorf("failed to send message: %v", err)
-	}
-	return &sendResp, nil
+func SendLocationMessage(ctx context.Context, client *whatsmeow.Client, evt *events.Message, location Location) (*whatsmeow.SendResponse, error) {
+	return SendLocationMessageTo(ctx, client, evt.Info.Chat, location)
 }
 
-func SendPolls(client *whatsmeow.Client, evt *events.Message, question string, pollOptions []string, onlyOnce bool) (*whatsmeow.SendResponse, error) {
-	// Create options for the PollCreationMessage
-	var options []*waProto.PollCreationMessage_Option
-	for _, optionText := range pollOptions {
-		options = append(options, &waProto.PollCreationMessage_Option{
-			OptionName: proto.String(optionText),
-		})
-	}
+func SendLocationReply(ctx context.Context, client *whatsmeow.Client, evt *events.Message, location Location) (*whatsmeow.SendResponse, error) {
+	return SendBuilt(ctx, client, evt.Info.Chat, NewLocation(location).ReplyTo(evt))
+}
 
-	msg := &waProto.Message{
-		PollCreationMessage: &waProto.PollCreationMessage{
-			Name:                   proto.String(question),
-			Options:                options,
-			SelectableOptionsCount: proto.Uint32(uint32(btoi(onlyOnce))), // Fix
-		},
-	}
+// SendPollsTo sends the same message as SendPolls to an arbitrary recipient
+func SendPollsTo(ctx context.Context, client *whatsmeow.Client, to types.JID, question string, pollOptions []string, onlyOnce bool) (*whatsmeow.SendResponse, error) {
+	return SendBuilt(ctx, client, to, NewPoll(question, pollOptions, onlyOnce))
+}
 
-	sendResp, err := client.SendMessage(context.Background(), evt.Info.Chat, msg)
-	if err != nil {
-		return nil, fmt.Errorf("failed to send message: %v", err)
-	}
-	return &sendResp, nil
+func SendPolls(ctx context.Context, client *whatsmeow.Client, evt *events.Message, question string, pollOptions []string, onlyOnce bool) (*whatsmeow.SendResponse, error) {
+	return SendPollsTo(ctx, client, evt.Info.Chat, question, pollOptions, onlyOnce)
 }
 
-func DeleteMessage(client *whatsmeow.Client, evt *events.Message, messageID string) (*whatsmeow.SendResponse, error) {
+func DeleteMessage(ctx context.Context, client *whatsmeow.Client, evt *events.Message, messageID string) (*whatsmeow.SendResponse, error) {
 	// Construct ProtocolMessage to revoke the message
 	revokeMsg := &waProto.Message{
 		ProtocolMessage: &waProto.ProtocolMessage{
@@ -756,9 +260,9 @@ func DeleteMessage(client *whatsmeow.Client, evt *events.Message, messageID stri
 	}
 
 	// Send the revoke message using the JID
-	getres, err := client.SendMessage(context.Background(), evt.Info.Chat, revokeMsg)
+	getres, err := client.SendMessage(ctx, evt.Info.Chat, revokeMsg)
 	if err != nil {
-		return nil, fmt.Errorf("failed to delete message: %v", err)
+		return nil, fmt.Errorf("failed to delete message: %w", err)
 	}
 
 	return &getres, nil
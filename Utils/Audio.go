@@ -0,0 +1,147 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+
+	ffmpeg "github.com/u2takey/ffmpeg-go"
+)
+
+// VoiceNoteMimeType is what WhatsApp expects for push-to-talk audio
+const VoiceNoteMimeType = "audio/ogg; codecs=opus"
+
+// WaveformSamples is the number of bars WhatsApp draws for a voice note
+const WaveformSamples = 64
+
+// waveformSampleRate is the rate audio is decoded at to compute the waveform; the shape doesn't need more detail
+const waveformSampleRate = 8000
+
+// VoiceNote is audio transcoded to OGG/Opus with the metadata phones attach to recorded voice notes
+type VoiceNote struct {
+	Data     []byte
+	Seconds  uint32
+	Waveform []byte
+}
+
+// ConvertToVoiceNote transcodes any audio (or the audio track of a video) to mono OGG/Opus
+// and computes its duration and 64-bar waveform. Audio that already is OGG/Opus is kept as it is.
+func ConvertToVoiceNote(ctx context.Context, media *Media) (*VoiceNote, error) {
+	tempDir, err := os.MkdirTemp("", "voicenote")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temp directory: %w", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	inputPath := filepath.Join(tempDir, "input")
+	if err := os.WriteFile(inputPath, media.Data, 0o600); err != nil {
+		return nil, fmt.Errorf("failed to write temp file: %w", err)
+	}
+
+	voice := &VoiceNote{Data: media.Data}
+	outputPath := inputPath
+	if !isOggOpus(ctx, media, inputPath) {
+		outputPath = filepath.Join(tempDir, "voice.ogg")
+		err = runStream(ctx, ffmpeg.Input(inputPath).
+			Output(outputPath, ffmpeg.KwArgs{
+				"vn":          "",
+				"ac":          1,
+				"ar":          48000,
+				"c:a":         "libopus",
+				"b:a":         "32k",
+				"application": "voip",
+				"f":           "ogg",
+			}).
+			OverWriteOutput())
+		if err != nil {
+			return nil, fmt.Errorf("failed to transcode audio: %w", err)
+		}
+
+		voice.Data, err = os.ReadFile(outputPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read transcoded audio: %w", err)
+		}
+	}
+
+	if info, err := ProbeFile(ctx, outputPath); err == nil {
+		voice.Seconds = uint32(math.Round(info.Duration))
+	}
+
+	// A missing waveform only makes the voice note look flat, so it isn't worth failing the send over
+	if waveform, err := computeWaveform(ctx, outputPath); err == nil {
+		voice.Waveform = waveform
+	}
+	return voice, nil
+}
+
+// isOggOpus reports whether media is already an OGG file with an Opus audio track
+func isOggOpus(ctx context.Context, media *Media, path string) bool {
+	if media.MimeType != "audio/ogg" && media.MimeType != "application/ogg" && media.MimeType != "audio/opus" {
+		return false
+	}
+	info, err := ProbeFile(ctx, path)
+	return err == nil && info.AudioCodec == "opus" && info.VideoCodec == "" && strings.Contains(info.FormatName, "ogg")
+}
+
+// AudioDuration returns the length of audio media in whole seconds
+func AudioDuration(ctx context.Context, media *Media) (uint32, error) {
+	info, err := ProbeMedia(ctx, media)
+	if err != nil {
+		return 0, err
+	}
+	return uint32(math.Round(info.Duration)), nil
+}
+
+// computeWaveform decodes audio to PCM and reduces it to WaveformSamples bars scaled 0-100
+func computeWaveform(ctx context.Context, path string) ([]byte, error) {
+	pcm := new(bytes.Buffer)
+	err := runStream(ctx, ffmpeg.Input(path).
+		Output("pipe:1", ffmpeg.KwArgs{"f": "s16le", "ac": 1, "ar": waveformSampleRate}).
+		WithOutput(pcm))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode audio: %w", err)
+	}
+
+	sampleCount := pcm.Len() / 2
+	if sampleCount == 0 {
+		return nil, fmt.Errorf("audio has no samples")
+	}
+	samples := make([]int16, sampleCount)
+	if err := binary.Read(bytes.NewReader(pcm.Bytes()[:sampleCount*2]), binary.LittleEndian, samples); err != nil {
+		return nil, fmt.Errorf("failed to read samples: %w", err)
+	}
+
+	// Average the loudness of each block of samples
+	levels := make([]float64, WaveformSamples)
+	var peak float64
+	for i := range levels {
+		start := i * sampleCount / WaveformSamples
+		end := (i + 1) * sampleCount / WaveformSamples
+		if end <= start {
+			end = start + 1
+		}
+		if end > sampleCount {
+			end = sampleCount
+		}
+		var sum float64
+		for _, sample := range samples[start:end] {
+			sum += math.Abs(float64(sample))
+		}
+		levels[i] = sum / float64(end-start)
+		peak = math.Max(peak, levels[i])
+	}
+
+	waveform := make([]byte, WaveformSamples)
+	if peak == 0 {
+		return waveform, nil
+	}
+	for i, level := range levels {
+		waveform[i] = byte(math.Round(level / peak * 100))
+	}
+	return waveform, nil
+}
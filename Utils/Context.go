@@ -0,0 +1,28 @@
+package utils
+
+import "context"
+
+// AwaitContext runs fn, which can't be cancelled, and waits for its result until ctx is done.
+// If ctx ends first it returns ctx.Err() right away while fn keeps running in the background;
+// its result is dropped and the goroutine exits as soon as fn returns. Use it for library calls
+// that take no context, and only for calls that end on their own.
+func AwaitContext[T any](ctx context.Context, fn func() (T, error)) (T, error) {
+	type result struct {
+		value T
+		err   error
+	}
+	// Buffered so the goroutine never blocks on sending after the caller stopped waiting
+	done := make(chan result, 1)
+	go func() {
+		value, err := fn()
+		done <- result{value, err}
+	}()
+
+	select {
+	case <-ctx.Done():
+		var zero T
+		return zero, ctx.Err()
+	case res := <-done:
+		return res.value, res.err
+	}
+}
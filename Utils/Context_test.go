@@ -0,0 +1,45 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestAwaitContext(t *testing.T) {
+	errFailed := errors.New("failed")
+	tests := []struct {
+		name  string
+		fn    func() (int, error)
+		want  int
+		err   error
+		abort bool
+	}{
+		{name: "result", fn: func() (int, error) { return 42, nil }, want: 42},
+		{name: "error", fn: func() (int, error) { return 0, errFailed }, err: errFailed},
+		{name: "context ends first", fn: func() (int, error) { time.Sleep(time.Second); return 42, nil }, err: context.Canceled, abort: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			if tt.abort {
+				time.AfterFunc(10*time.Millisecond, cancel)
+			}
+
+			start := time.Now()
+			got, err := AwaitContext(ctx, tt.fn)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("error = %v, want %v", err, tt.err)
+			}
+			if got != tt.want {
+				t.Errorf("result = %d, want %d", got, tt.want)
+			}
+			if tt.abort && time.Since(start) > 500*time.Millisecond {
+				t.Errorf("returned after %v instead of when the context ended", time.Since(start))
+			}
+		})
+	}
+}
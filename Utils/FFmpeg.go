@@ -0,0 +1,203 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	ffmpeg "github.com/u2takey/ffmpeg-go"
+)
+
+// ffmpegErrorLines is how many lines of ffmpeg's output are kept for error messages
+const ffmpegErrorLines = 5
+
+// runStream runs a compiled ffmpeg stream and kills the process if ctx is done before it finishes.
+// When ffmpeg fails, the error ends with the last lines it wrote to stderr.
+func runStream(ctx context.Context, stream *ffmpeg.Stream) error {
+	cmd := stream.Compile()
+	stderr := &tailBuffer{limit: 4 << 10}
+	if cmd.Stderr != nil {
+		cmd.Stderr = io.MultiWriter(cmd.Stderr, stderr)
+	} else {
+		cmd.Stderr = stderr
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start ffmpeg: %w", err)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- cmd.Wait()
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			if output := stderr.lastLines(ffmpegErrorLines); output != "" {
+				return fmt.Errorf("%w: %s", err, output)
+			}
+		}
+		return err
+	case <-ctx.Done():
+		_ = cmd.Process.Kill()
+		<-done
+		return ctx.Err()
+	}
+}
+
+// tailBuffer keeps the last limit bytes written to it, so a chatty process can't fill memory
+type tailBuffer struct {
+	limit int
+	data  []byte
+}
+
+func (b *tailBuffer) Write(p []byte) (int, error) {
+	n := len(p)
+	if len(p) > b.limit {
+		p = p[len(p)-b.limit:]
+	}
+	if overflow := len(b.data) + len(p) - b.limit; overflow > 0 {
+		b.data = b.data[overflow:]
+	}
+	b.data = append(b.data, p...)
+	return n, nil
+}
+
+// lastLines returns up to n of the last non-empty lines, joined with " | "
+func (b *tailBuffer) lastLines(n int) string {
+	var lines []string
+	for _, line := range strings.Split(strings.ReplaceAll(string(b.data), "\r", "\n"), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return strings.Join(lines, " | ")
+}
+
+// ProbeInfo is the part of ffprobe's report the senders care about
+type ProbeInfo struct {
+	FormatName string
+	Duration   float64
+	Size       int64
+	BitRate    int64
+	Width      int
+	Height     int
+	VideoCodec string
+	AudioCodec string
+}
+
+type probeOutput struct {
+	Format struct {
+		FormatName string `json:"format_name"`
+		Duration   string `json:"duration"`
+		Size       string `json:"size"`
+		BitRate    string `json:"bit_rate"`
+	} `json:"format"`
+	Streams []struct {
+		CodecType string            `json:"codec_type"`
+		CodecName string            `json:"codec_name"`
+		Width     int               `json:"width"`
+		Height    int               `json:"height"`
+		Duration  string            `json:"duration"`
+		Tags      map[string]string `json:"tags"`
+		SideData  []struct {
+			Rotation int `json:"rotation"`
+		} `json:"side_data_list"`
+	} `json:"streams"`
+}
+
+// ProbeFile runs ffprobe on a file and returns its duration, dimensions and codecs
+func ProbeFile(ctx context.Context, path string) (*ProbeInfo, error) {
+	cmd := exec.CommandContext(ctx, "ffprobe", "-v", "error", "-show_format", "-show_streams", "-of", "json", path)
+	stdout := new(bytes.Buffer)
+	stderr := new(bytes.Buffer)
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, fmt.Errorf("failed to probe media: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	var out probeOutput
+	if err := json.Unmarshal(stdout.Bytes(), &out); err != nil {
+		return nil, fmt.Errorf("failed to parse ffprobe output: %w", err)
+	}
+
+	info := &ProbeInfo{FormatName: out.Format.FormatName}
+	info.Duration, _ = strconv.ParseFloat(out.Format.Duration, 64)
+	info.Size, _ = strconv.ParseInt(out.Format.Size, 10, 64)
+	info.BitRate, _ = strconv.ParseInt(out.Format.BitRate, 10, 64)
+
+	for _, stream := range out.Streams {
+		switch stream.CodecType {
+		case "video":
+			if info.VideoCodec != "" {
+				continue
+			}
+			info.VideoCodec = stream.CodecName
+			info.Width, info.Height = stream.Width, stream.Height
+			// Phones record portrait video as rotated landscape frames
+			rotation, _ := strconv.Atoi(stream.Tags["rotate"])
+			for _, side := range stream.SideData {
+				if side.Rotation != 0 {
+					rotation = side.Rotation
+				}
+			}
+			if rotation%180 != 0 {
+				info.Width, info.Height = info.Height, info.Width
+			}
+		case "audio":
+			if info.AudioCodec == "" {
+				info.AudioCodec = stream.CodecName
+			}
+		}
+		if info.Duration == 0 {
+			info.Duration, _ = strconv.ParseFloat(stream.Duration, 64)
+		}
+	}
+	return info, nil
+}
+
+// ProbeMedia runs ffprobe on in-memory media
+func ProbeMedia(ctx context.Context, media *Media) (*ProbeInfo, error) {
+	path, cleanup, err := writeTempMedia(media)
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+	return ProbeFile(ctx, path)
+}
+
+// writeTempMedia writes media to a temp file for tools that need a seekable path
+func writeTempMedia(media *Media) (string, func(), error) {
+	tempDir, err := os.MkdirTemp("", "easymeow")
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to create temp directory: %w", err)
+	}
+	cleanup := func() { os.RemoveAll(tempDir) }
+
+	path := filepath.Join(tempDir, "input"+filepath.Ext(media.FileName))
+	if err := os.WriteFile(path, media.Data, 0o600); err != nil {
+		cleanup()
+		return "", nil, fmt.Errorf("failed to write temp file: %w", err)
+	}
+	return path, cleanup, nil
+}
+
+// IsToolMissing reports whether err came from ffmpeg or ffprobe not being installed
+func IsToolMissing(err error) bool {
+	return errors.Is(err, exec.ErrNotFound)
+}
@@ -0,0 +1,61 @@
+package utils
+
+import (
+	"context"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	ffmpeg "github.com/u2takey/ffmpeg-go"
+)
+
+func TestTailBuffer(t *testing.T) {
+	tests := []struct {
+		name   string
+		writes []string
+		limit  int
+		lines  int
+		want   string
+	}{
+		{name: "short output", writes: []string{"first\nsecond\n"}, limit: 64, lines: 5, want: "first | second"},
+		{name: "only the last lines", writes: []string{"a\nb\nc\nd\n"}, limit: 64, lines: 2, want: "c | d"},
+		{name: "split writes", writes: []string{"Invalid da", "ta found\n"}, limit: 64, lines: 5, want: "Invalid data found"},
+		{name: "progress lines", writes: []string{"frame=1\rframe=2\r\nError\n"}, limit: 64, lines: 2, want: "frame=2 | Error"},
+		{name: "blank lines skipped", writes: []string{"error\n\n  \n"}, limit: 64, lines: 5, want: "error"},
+		{name: "bounded", writes: []string{strings.Repeat("x", 100) + "\nend\n"}, limit: 8, lines: 5, want: "xxx | end"},
+		{name: "bounded over writes", writes: []string{"old line\n", "new\n", "last\n"}, limit: 9, lines: 5, want: "new | last"},
+		{name: "nothing written", limit: 64, lines: 5, want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buf := &tailBuffer{limit: tt.limit}
+			for _, write := range tt.writes {
+				if n, err := buf.Write([]byte(write)); n != len(write) || err != nil {
+					t.Fatalf("Write = %d, %v", n, err)
+				}
+			}
+			if len(buf.data) > tt.limit {
+				t.Errorf("kept %d bytes, limit %d", len(buf.data), tt.limit)
+			}
+			if got := buf.lastLines(tt.lines); got != tt.want {
+				t.Errorf("lastLines = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRunStreamError(t *testing.T) {
+	if _, err := exec.LookPath("ffmpeg"); err != nil {
+		t.Skip("ffmpeg is not installed")
+	}
+	missing := filepath.Join(t.TempDir(), "missing.mp4")
+	err := runStream(context.Background(), ffmpeg.Input(missing).Output(filepath.Join(t.TempDir(), "out.mp4")))
+	if err == nil {
+		t.Fatal("expected an error for a missing input")
+	}
+	if !strings.Contains(err.Error(), "missing.mp4") {
+		t.Errorf("error doesn't include ffmpeg's output: %v", err)
+	}
+}
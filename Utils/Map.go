@@ -0,0 +1,168 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	_ "image/png"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nfnt/resize"
+)
+
+// MapTiles configures where location thumbnails get their map from
+type MapTiles struct {
+	// URL is the tile server template with {z}, {x} and {y} placeholders. An empty URL disables map thumbnails.
+	URL string
+	// UserAgent identifies the program to the tile server, which public servers require
+	UserAgent string
+	// Zoom is the map zoom level, 15 shows a few streets around the point
+	Zoom int
+	// Client fetches the tiles. A nil client uses http.DefaultClient.
+	Client *http.Client
+	// Timeout limits fetching all tiles of one thumbnail
+	Timeout time.Duration
+}
+
+// DefaultMapTiles is used for location thumbnails. Its URL is empty, so locations are sent without a map
+// until you point it at a tile server, e.g. DefaultMapTiles = OpenStreetMapTiles or your own server.
+var DefaultMapTiles = MapTiles{
+	UserAgent: "easy-meow",
+	Zoom:      15,
+	Timeout:   10 * time.Second,
+}
+
+// OpenStreetMapTiles uses OpenStreetMap's public tile server. Its usage policy forbids heavy use and asks for
+// an identifying UserAgent, see https://operations.osmfoundation.org/policies/tiles/.
+var OpenStreetMapTiles = MapTiles{
+	URL:       "https://tile.openstreetmap.org/{z}/{x}/{y}.png",
+	UserAgent: "easy-meow",
+	Zoom:      15,
+	Timeout:   10 * time.Second,
+}
+
+// ErrMapTilesDisabled is returned when rendering a map without a tile server configured
+var ErrMapTilesDisabled = errors.New("map thumbnails are disabled")
+
+const (
+	tileSize     = 256
+	maxTileBytes = 1 << 20
+	mapThumbSize = 100
+)
+
+// MapThumbnail renders a small JPEG map centered on the coordinates with a pin on them, using DefaultMapTiles.
+// It returns ErrMapTilesDisabled unless a tile server has been configured.
+func MapThumbnail(ctx context.Context, latitude, longitude float64) ([]byte, error) {
+	return DefaultMapTiles.Thumbnail(ctx, latitude, longitude)
+}
+
+// Thumbnail renders a small JPEG map centered on the coordinates with a pin on them
+func (t MapTiles) Thumbnail(ctx context.Context, latitude, longitude float64) ([]byte, error) {
+	if t.URL == "" {
+		return nil, ErrMapTilesDisabled
+	}
+	if latitude < -85.05 || latitude > 85.05 || longitude < -180 || longitude > 180 {
+		return nil, fmt.Errorf("coordinates out of range: %f, %f", latitude, longitude)
+	}
+	if t.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, t.Timeout)
+		defer cancel()
+	}
+
+	// Position of the point in pixels on the whole map at this zoom (Web Mercator)
+	scale := float64(tileSize) * math.Exp2(float64(t.Zoom))
+	latRad := latitude * math.Pi / 180
+	px := int((longitude + 180) / 360 * scale)
+	py := int((1 - math.Log(math.Tan(latRad)+1/math.Cos(latRad))/math.Pi) / 2 * scale)
+
+	// The tile-sized window around the point spans at most 2x2 tiles
+	window := image.Rect(px-tileSize/2, py-tileSize/2, px+tileSize/2, py+tileSize/2)
+	canvas := image.NewRGBA(image.Rect(0, 0, tileSize, tileSize))
+	tiles := 1 << t.Zoom
+	for ty := floorDiv(window.Min.Y, tileSize); ty <= floorDiv(window.Max.Y-1, tileSize); ty++ {
+		for tx := floorDiv(window.Min.X, tileSize); tx <= floorDiv(window.Max.X-1, tileSize); tx++ {
+			if ty < 0 || ty >= tiles {
+				continue
+			}
+			tile, err := t.fetchTile(ctx, ((tx%tiles)+tiles)%tiles, ty)
+			if err != nil {
+				return nil, err
+			}
+			offset := image.Pt(tx*tileSize, ty*tileSize).Sub(window.Min)
+			draw.Draw(canvas, tile.Bounds().Add(offset), tile, tile.Bounds().Min, draw.Src)
+		}
+	}
+
+	resized := resize.Resize(mapThumbSize, mapThumbSize, canvas, resize.Lanczos3)
+	thumb := image.NewRGBA(image.Rect(0, 0, mapThumbSize, mapThumbSize))
+	draw.Draw(thumb, thumb.Bounds(), resized, resized.Bounds().Min, draw.Src)
+	drawPin(thumb, mapThumbSize/2, mapThumbSize/2)
+
+	buf := new(bytes.Buffer)
+	if err := jpeg.Encode(buf, thumb, &jpeg.Options{Quality: 80}); err != nil {
+		return nil, fmt.Errorf("failed to encode map thumbnail: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+func (t MapTiles) fetchTile(ctx context.Context, x, y int) (image.Image, error) {
+	tileURL := strings.NewReplacer("{z}", strconv.Itoa(t.Zoom), "{x}", strconv.Itoa(x), "{y}", strconv.Itoa(y)).Replace(t.URL)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, tileURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to prepare map tile request: %w", err)
+	}
+	if t.UserAgent != "" {
+		req.Header.Set("User-Agent", t.UserAgent)
+	}
+	client := t.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download map tile: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, fmt.Errorf("failed to download map tile: unexpected status %s", resp.Status)
+	}
+
+	tile, _, err := image.Decode(io.LimitReader(resp.Body, maxTileBytes))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode map tile: %w", err)
+	}
+	return tile, nil
+}
+
+// drawPin marks the point with a red dot with a white border
+func drawPin(img *image.RGBA, cx, cy int) {
+	for y := -6; y <= 6; y++ {
+		for x := -6; x <= 6; x++ {
+			switch d := x*x + y*y; {
+			case d <= 16:
+				img.Set(cx+x, cy+y, color.RGBA{R: 0xe5, G: 0x39, B: 0x35, A: 0xff})
+			case d <= 36:
+				img.Set(cx+x, cy+y, color.White)
+			}
+		}
+	}
+}
+
+func floorDiv(a, b int) int {
+	q := a / b
+	if a%b != 0 && (a < 0) != (b < 0) {
+		q--
+	}
+	return q
+}
@@ -0,0 +1,189 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// MaxMediaSize caps how many bytes are read from readers and URLs, so a bad link cannot exhaust memory
+var MaxMediaSize int64 = 100 << 20
+
+// Media is media content loaded into memory, with its MIME type sniffed once from the data
+type Media struct {
+	Data     []byte
+	MimeType string
+	// FileName is the base name of the file or URL the media came from, if known
+	FileName string
+}
+
+// MediaSource is anything media can be loaded from: a file path, bytes, a reader or an http(s) URL
+type MediaSource interface {
+	Open(ctx context.Context) (*Media, error)
+}
+
+type pathSource string
+
+type bytesSource struct {
+	data     []byte
+	fileName string
+}
+
+type readerSource struct {
+	reader   io.Reader
+	fileName string
+}
+
+type urlSource struct {
+	url    string
+	client *http.Client
+}
+
+// FromPath loads media from a file on disk
+func FromPath(path string) MediaSource {
+	return pathSource(path)
+}
+
+// FromBytes uses media that is already in memory. The optional file name helps MIME detection and document names.
+func FromBytes(data []byte, fileName ...string) MediaSource {
+	src := bytesSource{data: data}
+	if len(fileName) > 0 {
+		src.fileName = fileName[0]
+	}
+	return src
+}
+
+// FromReader reads media from r, up to MaxMediaSize bytes
+func FromReader(r io.Reader, fileName ...string) MediaSource {
+	src := readerSource{reader: r}
+	if len(fileName) > 0 {
+		src.fileName = fileName[0]
+	}
+	return src
+}
+
+// FromURL downloads media over http(s), up to MaxMediaSize bytes. A nil client uses http.DefaultClient.
+func FromURL(rawURL string, client ...*http.Client) MediaSource {
+	src := urlSource{url: rawURL, client: http.DefaultClient}
+	if len(client) > 0 && client[0] != nil {
+		src.client = client[0]
+	}
+	return src
+}
+
+func (p pathSource) Open(ctx context.Context) (*Media, error) {
+	data, err := os.ReadFile(string(p))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+	return NewMedia(data, filepath.Base(string(p)), ""), nil
+}
+
+func (b bytesSource) Open(ctx context.Context) (*Media, error) {
+	if len(b.data) == 0 {
+		return nil, fmt.Errorf("media data is empty")
+	}
+	return NewMedia(b.data, b.fileName, ""), nil
+}
+
+func (r readerSource) Open(ctx context.Context) (*Media, error) {
+	data, err := readLimited(r.reader)
+	if err != nil {
+		return nil, err
+	}
+	return NewMedia(data, r.fileName, ""), nil
+}
+
+func (u urlSource) Open(ctx context.Context) (*Media, error) {
+	parsed, err := url.Parse(u.url)
+	if err != nil {
+		return nil, fmt.Errorf("invalid media URL: %w", err)
+	}
+	if parsed.Scheme != "http" && parsed.Scheme != "https" {
+		return nil, fmt.Errorf("unsupported media URL scheme: %s", parsed.Scheme)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to prepare media request: %w", err)
+	}
+	resp, err := u.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download media: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, fmt.Errorf("failed to download media: unexpected status %s", resp.Status)
+	}
+	if resp.ContentLength > MaxMediaSize {
+		return nil, fmt.Errorf("media is too large: %d bytes (limit %d)", resp.ContentLength, MaxMediaSize)
+	}
+
+	data, err := readLimited(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	return NewMedia(data, path.Base(parsed.Path), resp.Header.Get("Content-Type")), nil
+}
+
+// NewMedia wraps data in a Media, sniffing the MIME type from the content and
+// falling back to the declared type and then the file extension when sniffing is inconclusive
+func NewMedia(data []byte, fileName string, declaredType string) *Media {
+	if fileName == "." || fileName == "/" {
+		fileName = ""
+	}
+	return &Media{
+		Data:     data,
+		MimeType: DetectMimeType(data, fileName, declaredType),
+		FileName: fileName,
+	}
+}
+
+// DetectMimeType sniffs the MIME type of data, using the declared type or the file extension when the content alone isn't enough
+func DetectMimeType(data []byte, fileName string, declaredType string) string {
+	sniffed := http.DetectContentType(data)
+	if idx := strings.IndexByte(sniffed, ';'); idx >= 0 && !strings.HasPrefix(sniffed, "text/") {
+		sniffed = sniffed[:idx]
+	}
+	if sniffed != "application/octet-stream" && sniffed != "text/plain; charset=utf-8" {
+		return sniffed
+	}
+
+	if declaredType != "" {
+		if mediaType, _, err := mime.ParseMediaType(declaredType); err == nil && mediaType != "application/octet-stream" {
+			return mediaType
+		}
+	}
+	if ext := filepath.Ext(fileName); ext != "" {
+		if byExt := mime.TypeByExtension(ext); byExt != "" {
+			if mediaType, _, err := mime.ParseMediaType(byExt); err == nil {
+				return mediaType
+			}
+		}
+	}
+	return sniffed
+}
+
+func readLimited(r io.Reader) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	n, err := io.Copy(buf, io.LimitReader(r, MaxMediaSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read media: %w", err)
+	}
+	if n > MaxMediaSize {
+		return nil, fmt.Errorf("media is too large (limit %d bytes)", MaxMediaSize)
+	}
+	if n == 0 {
+		return nil, fmt.Errorf("media data is empty")
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,130 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// withMaxMediaSize lowers MaxMediaSize for one test
+func withMaxMediaSize(t *testing.T, size int64) {
+	t.Helper()
+	previous := MaxMediaSize
+	MaxMediaSize = size
+	t.Cleanup(func() { MaxMediaSize = previous })
+}
+
+func TestDetectMimeType(t *testing.T) {
+	png := []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+	unknown := []byte{0x00, 0x01, 0x02, 0x03}
+
+	tests := []struct {
+		name     string
+		data     []byte
+		fileName string
+		declared string
+		want     string
+	}{
+		{name: "sniffed", data: png, want: "image/png"},
+		{name: "sniffed beats declared type and extension", data: png, fileName: "photo.jpg", declared: "image/jpeg", want: "image/png"},
+		{name: "declared type", data: unknown, declared: "audio/mpeg", want: "audio/mpeg"},
+		{name: "declared type without parameters", data: unknown, declared: "audio/ogg; codecs=opus", want: "audio/ogg"},
+		{name: "declared type beats extension", data: unknown, fileName: "song.mp4", declared: "audio/mpeg", want: "audio/mpeg"},
+		{name: "octet-stream falls back to extension", data: unknown, fileName: "report.pdf", declared: "application/octet-stream", want: "application/pdf"},
+		{name: "invalid declared type falls back to extension", data: unknown, fileName: "report.pdf", declared: "not a type;;", want: "application/pdf"},
+		{name: "extension", data: unknown, fileName: "report.pdf", want: "application/pdf"},
+		{name: "text by extension", data: []byte("a,b\n1,2\n"), fileName: "table.csv", want: "text/csv"},
+		{name: "nothing to go on", data: unknown, want: "application/octet-stream"},
+		{name: "plain text", data: []byte("hello"), want: "text/plain; charset=utf-8"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := DetectMimeType(tt.data, tt.fileName, tt.declared); got != tt.want {
+				t.Errorf("DetectMimeType = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFromReaderLimit(t *testing.T) {
+	withMaxMediaSize(t, 16)
+	tests := []struct {
+		name string
+		data string
+		err  bool
+	}{
+		{name: "under the limit", data: "short"},
+		{name: "at the limit", data: strings.Repeat("a", 16)},
+		{name: "over the limit", data: strings.Repeat("a", 17), err: true},
+		{name: "empty", data: "", err: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			media, err := FromReader(strings.NewReader(tt.data)).Open(context.Background())
+			if tt.err {
+				if err == nil {
+					t.Fatalf("expected an error, got %d bytes", len(media.Data))
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(media.Data) != tt.data {
+				t.Errorf("data = %q, want %q", media.Data, tt.data)
+			}
+		})
+	}
+}
+
+func TestFromURLLimit(t *testing.T) {
+	withMaxMediaSize(t, 16)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body := []byte(strings.Repeat("a", 8))
+		if r.URL.Query().Get("large") != "" {
+			body = bytes.Repeat([]byte("a"), 32)
+		}
+		w.Header().Set("Content-Type", "audio/mpeg")
+		if r.URL.Query().Get("chunked") != "" {
+			// Flushing before writing the body leaves out Content-Length
+			w.WriteHeader(http.StatusOK)
+			w.(http.Flusher).Flush()
+		}
+		w.Write(body)
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name string
+		path string
+		err  bool
+	}{
+		{name: "under the limit", path: "/song.mp3"},
+		{name: "under the limit without length", path: "/song.mp3?chunked=1"},
+		{name: "declared length over the limit", path: "/song.mp3?large=1", err: true},
+		{name: "over the limit without length", path: "/song.mp3?large=1&chunked=1", err: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			media, err := FromURL(server.URL+tt.path, server.Client()).Open(context.Background())
+			if tt.err {
+				if err == nil {
+					t.Fatalf("expected an error, got %d bytes", len(media.Data))
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if media.MimeType != "audio/mpeg" || media.FileName != "song.mp3" {
+				t.Errorf("got %q named %q, want audio/mpeg named song.mp3", media.MimeType, media.FileName)
+			}
+		})
+	}
+}
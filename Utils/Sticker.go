@@ -0,0 +1,197 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"image/gif"
+	"os"
+	"path/filepath"
+	"strings"
+
+	ffmpeg "github.com/u2takey/ffmpeg-go"
+)
+
+// WhatsApp sticker limits
+const (
+	StickerSize            = 512
+	MaxStaticStickerSize   = 100 << 10
+	MaxAnimatedStickerSize = 500 << 10
+	// MaxAnimatedStickerSeconds is how much of a video or GIF ends up in an animated sticker
+	MaxAnimatedStickerSeconds = 10
+)
+
+// stickerQualities are tried in order until the sticker fits the size limit
+var stickerQualities = []int{80, 60, 45, 30, 15}
+
+// StickerMetadata is the sticker-pack information WhatsApp shows under a sticker
+type StickerMetadata struct {
+	PackID    string
+	PackName  string
+	Publisher string
+	Emojis    []string
+}
+
+// Sticker is a WhatsApp-ready WebP sticker
+type Sticker struct {
+	Data     []byte
+	Width    int
+	Height   int
+	Animated bool
+}
+
+// ConvertToSticker turns PNG, JPEG, GIF, WebP or video media into a 512x512 WebP sticker within WhatsApp's size
+// limits, with the pack metadata embedded. Static and animated input produce static and animated stickers.
+func ConvertToSticker(ctx context.Context, media *Media, metadata StickerMetadata) (*Sticker, error) {
+	var exif []byte
+	reserve := 0
+	if !metadata.isEmpty() {
+		exif = metadata.exif()
+		// The EXIF chunk and a VP8X header, if the file needs one, count against the size limit too
+		reserve = webpChunkHeaderSize + len(exif) + len(exif)%2 + webpChunkHeaderSize + webpVP8XSize
+	}
+
+	sticker, err := encodeSticker(ctx, media, reserve)
+	if err != nil {
+		return nil, err
+	}
+
+	if exif != nil {
+		withEXIF, err := SetWebPEXIF(sticker.Data, exif)
+		if err != nil {
+			return nil, fmt.Errorf("failed to embed sticker metadata: %w", err)
+		}
+		sticker.Data = withEXIF
+	}
+	if limit := sticker.limit(); len(sticker.Data) > limit {
+		return nil, fmt.Errorf("sticker is %d bytes with its metadata (limit %d)", len(sticker.Data), limit)
+	}
+	return sticker, nil
+}
+
+// limit is the largest size WhatsApp accepts for the sticker
+func (s *Sticker) limit() int {
+	if s.Animated {
+		return MaxAnimatedStickerSize
+	}
+	return MaxStaticStickerSize
+}
+
+// encodeSticker converts media to a sticker that leaves reserve bytes of the size limit free for metadata
+func encodeSticker(ctx context.Context, media *Media, reserve int) (*Sticker, error) {
+	// WebP that already fits the rules is kept as-is, which is also the only way animated WebP gets through
+	if media.MimeType == "image/webp" {
+		info, err := ParseWebP(media.Data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read WebP sticker: %w", err)
+		}
+		limit := MaxStaticStickerSize - reserve
+		if info.Animated {
+			limit = MaxAnimatedStickerSize - reserve
+		}
+		if info.Width == StickerSize && info.Height == StickerSize && len(media.Data) <= limit {
+			return &Sticker{Data: media.Data, Width: info.Width, Height: info.Height, Animated: info.Animated}, nil
+		}
+		if info.Animated {
+			return nil, fmt.Errorf("animated WebP stickers must already be %dx%d and at most %d bytes with their metadata", StickerSize, StickerSize, limit)
+		}
+	}
+
+	animated := isVideoContentType(media.MimeType)
+	if media.MimeType == "image/gif" {
+		frames, err := gif.DecodeAll(bytes.NewReader(media.Data))
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode GIF: %w", err)
+		}
+		animated = len(frames.Image) > 1
+	} else if !animated && !isImageContentType(media.MimeType) && media.MimeType != "image/webp" {
+		return nil, fmt.Errorf("unsupported sticker type: %s", media.MimeType)
+	}
+
+	tempDir, err := os.MkdirTemp("", "sticker")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temp directory: %w", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	inputPath := filepath.Join(tempDir, "input")
+	if err := os.WriteFile(inputPath, media.Data, 0o600); err != nil {
+		return nil, fmt.Errorf("failed to write temp file: %w", err)
+	}
+	outputPath := filepath.Join(tempDir, "sticker.webp")
+
+	limit := MaxStaticStickerSize - reserve
+	if animated {
+		limit = MaxAnimatedStickerSize - reserve
+	}
+
+	// Fit inside 512x512 and pad the rest with transparency
+	filter := fmt.Sprintf("scale=%d:%d:force_original_aspect_ratio=decrease,format=rgba,pad=%d:%d:(ow-iw)/2:(oh-ih)/2:color=0x00000000",
+		StickerSize, StickerSize, StickerSize, StickerSize)
+	if animated {
+		filter = "fps=15," + filter
+	}
+
+	var lastSize int
+	for _, quality := range stickerQualities {
+		args := ffmpeg.KwArgs{
+			"vf":       filter,
+			"vcodec":   "libwebp",
+			"lossless": 0,
+			"q:v":      quality,
+			"an":       "",
+		}
+		if animated {
+			args["loop"] = 0
+			args["t"] = MaxAnimatedStickerSeconds
+		} else {
+			args["frames:v"] = 1
+		}
+
+		err := runStream(ctx, ffmpeg.Input(inputPath).Output(outputPath, args).OverWriteOutput())
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert sticker: %w", err)
+		}
+
+		data, err := os.ReadFile(outputPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read converted sticker: %w", err)
+		}
+		if len(data) <= limit {
+			return &Sticker{Data: data, Width: StickerSize, Height: StickerSize, Animated: animated}, nil
+		}
+		lastSize = len(data)
+	}
+
+	return nil, fmt.Errorf("sticker is still %d bytes at the lowest quality (limit %d)", lastSize, limit)
+}
+
+func (m StickerMetadata) isEmpty() bool {
+	return m.PackID == "" && m.PackName == "" && m.Publisher == "" && len(m.Emojis) == 0
+}
+
+// exif builds the TIFF block WhatsApp reads sticker-pack JSON from (tag 0x5741, type UNDEFINED)
+func (m StickerMetadata) exif() []byte {
+	packID := m.PackID
+	if packID == "" {
+		packID = strings.ToLower(strings.ReplaceAll(m.PackName+"."+m.Publisher, " ", "-"))
+	}
+	payload, _ := json.Marshal(map[string]interface{}{
+		"sticker-pack-id":        packID,
+		"sticker-pack-name":      m.PackName,
+		"sticker-pack-publisher": m.Publisher,
+		"emojis":                 append([]string{}, m.Emojis...),
+	})
+
+	exif := []byte{
+		0x49, 0x49, 0x2A, 0x00, 0x08, 0x00, 0x00, 0x00, // little endian TIFF header, first IFD at offset 8
+		0x01, 0x00, // one entry
+		0x41, 0x57, 0x07, 0x00, // tag 0x5741, type 7 (UNDEFINED)
+		0x00, 0x00, 0x00, 0x00, // payload length, filled in below
+		0x16, 0x00, 0x00, 0x00, // payload offset (22)
+	}
+	binary.LittleEndian.PutUint32(exif[14:18], uint32(len(payload)))
+	return append(exif, payload...)
+}
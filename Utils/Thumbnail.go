@@ -4,13 +4,13 @@ package utils
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"image"
 	"image/gif"
 	"image/jpeg"
 	"image/png"
 	"io"
-	"net/http"
 	"os"
 	"path/filepath"
 
@@ -21,35 +21,35 @@ import (
 
 // GetThumbnail generates a thumbnail from the given file path and returns the thumbnail as a byte slice
 func GetThumbnail(path string) ([]byte, error) {
-	// Create temp directory
-	tempDir, err := os.MkdirTemp("", "thumbnail")
-	if err != nil {
-		return nil, fmt.Errorf("failed to create temp directory: %w", err)
-	}
-	defer os.RemoveAll(tempDir)
+	return GetThumbnailContext(context.Background(), path)
+}
 
-	// Open the file
-	file, err := os.Open(path)
-	if err != nil {
-		return nil, fmt.Errorf("failed to open file: %w", err)
-	}
-	defer file.Close()
+// GetThumbnailContext is GetThumbnail with a context that can cancel the ffmpeg frame extraction
+func GetThumbnailContext(ctx context.Context, path string) ([]byte, error) {
+	return GetThumbnailFromSource(ctx, FromPath(path))
+}
 
-	// Detect file type
-	contentType, err := getFileContentType(file)
+// GetThumbnailFromSource loads media from any source and generates its thumbnail
+func GetThumbnailFromSource(ctx context.Context, source MediaSource) ([]byte, error) {
+	media, err := source.Open(ctx)
 	if err != nil {
 		return nil, err
 	}
+	return GetMediaThumbnail(ctx, media)
+}
 
+// GetMediaThumbnail generates a JPEG thumbnail from media that has already been loaded
+func GetMediaThumbnail(ctx context.Context, media *Media) ([]byte, error) {
 	var img image.Image
+	var err error
 
 	switch {
-	case isImageContentType(contentType):
-		img, err = decodeImage(file, contentType)
-	case isVideoContentType(contentType):
-		img, err = extractVideoThumbnail(path, tempDir)
+	case isImageContentType(media.MimeType):
+		img, err = decodeImage(bytes.NewReader(media.Data), media.MimeType)
+	case isVideoContentType(media.MimeType):
+		img, err = extractMediaVideoThumbnail(ctx, media)
 	default:
-		return nil, fmt.Errorf("unsupported file type: %s", contentType)
+		return nil, fmt.Errorf("unsupported file type: %s", media.MimeType)
 	}
 
 	if err != nil {
@@ -72,17 +72,19 @@ func GetThumbnail(path string) ([]byte, error) {
 	return thumbnailBuf.Bytes(), nil
 }
 
-func getFileContentType(file *os.File) (string, error) {
-	buffer := make([]byte, 512)
-	_, err := file.Read(buffer)
+// extractMediaVideoThumbnail writes in-memory video to a temp file so ffmpeg can seek in it
+func extractMediaVideoThumbnail(ctx context.Context, media *Media) (image.Image, error) {
+	tempDir, err := os.MkdirTemp("", "thumbnail")
 	if err != nil {
-		return "", fmt.Errorf("failed to read file header: %w", err)
+		return nil, fmt.Errorf("failed to create temp directory: %w", err)
 	}
-	_, err = file.Seek(0, io.SeekStart)
-	if err != nil {
-		return "", fmt.Errorf("failed to seek file: %w", err)
+	defer os.RemoveAll(tempDir)
+
+	videoPath := filepath.Join(tempDir, "input")
+	if err := os.WriteFile(videoPath, media.Data, 0o600); err != nil {
+		return nil, fmt.Errorf("failed to write temp video: %w", err)
 	}
-	return http.DetectContentType(buffer), nil
+	return extractVideoThumbnail(ctx, videoPath, tempDir)
 }
 
 func isImageContentType(contentType string) bool {
@@ -90,7 +92,8 @@ func isImageContentType(contentType string) bool {
 }
 
 func isVideoContentType(contentType string) bool {
-	return contentType == "video/mp4" || contentType == "video/mpeg" || contentType == "video/quicktime"
+	return contentType == "video/mp4" || contentType == "video/mpeg" || contentType == "video/quicktime" ||
+		contentType == "video/webm" || contentType == "video/x-matroska"
 }
 
 func decodeImage(file io.Reader, contentType string) (image.Image, error) {
@@ -110,14 +113,13 @@ func decodeImage(file io.Reader, contentType string) (image.Image, error) {
 	}
 }
 
-func extractVideoThumbnail(videoPath, tempDir string) (image.Image, error) {
+func extractVideoThumbnail(ctx context.Context, videoPath, tempDir string) (image.Image, error) {
 	thumbPath := filepath.Join(tempDir, "thumbnail.jpg")
 
-	err := ffmpeg.Input(videoPath).
+	err := runStream(ctx, ffmpeg.Input(videoPath).
 		Filter("select", ffmpeg.Args{fmt.Sprintf("gte(n,%d)", 1)}).
 		Output(thumbPath, ffmpeg.KwArgs{"vframes": 1, "format": "image2", "vcodec": "mjpeg"}).
-		OverWriteOutput().
-		Run()
+		OverWriteOutput())
 
 	if err != nil {
 		return nil, fmt.Errorf("failed to extract video thumbnail: %w", err)
@@ -0,0 +1,303 @@
+package utils
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	ffmpeg "github.com/u2takey/ffmpeg-go"
+)
+
+// Video is MP4 data ready for a VideoMessage, with the metadata WhatsApp displays
+type Video struct {
+	Data    []byte
+	Width   int
+	Height  int
+	Seconds uint32
+}
+
+// ConvertToGifVideo turns a GIF, animated WebP or any video into a silent H.264 MP4 suitable for
+// GifPlayback. WhatsApp loops GIF messages on its own, so the clip is encoded once.
+// Animated WebP needs an ffmpeg build that can decode it.
+func ConvertToGifVideo(ctx context.Context, media *Media) (*Video, error) {
+	if !isImageContentType(media.MimeType) && media.MimeType != "image/webp" && !isVideoContentType(media.MimeType) {
+		return nil, fmt.Errorf("unsupported GIF type: %s", media.MimeType)
+	}
+
+	tempDir, err := os.MkdirTemp("", "gif")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temp directory: %w", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	inputPath := filepath.Join(tempDir, "input")
+	if err := os.WriteFile(inputPath, media.Data, 0o600); err != nil {
+		return nil, fmt.Errorf("failed to write temp file: %w", err)
+	}
+	outputPath := filepath.Join(tempDir, "gif.mp4")
+
+	err = runStream(ctx, ffmpeg.Input(inputPath).
+		Output(outputPath, ffmpeg.KwArgs{
+			// H.264 in yuv420p needs even dimensions
+			"vf":       "scale=trunc(iw/2)*2:trunc(ih/2)*2",
+			"c:v":      "libx264",
+			"pix_fmt":  "yuv420p",
+			"movflags": "+faststart",
+			"an":       "",
+			"f":        "mp4",
+		}).
+		OverWriteOutput())
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert GIF to MP4: %w", err)
+	}
+
+	data, err := os.ReadFile(outputPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read converted GIF: %w", err)
+	}
+
+	video := &Video{Data: data}
+	if info, err := ProbeFile(ctx, outputPath); err == nil {
+		video.Width, video.Height = info.Width, info.Height
+		video.Seconds = uint32(math.Round(info.Duration))
+	}
+	return video, nil
+}
+
+// DefaultMaxVideoSize is the largest video WhatsApp reliably accepts as a VideoMessage
+const DefaultMaxVideoSize = 16 << 20
+
+// VideoOptions controls how videos are prepared before upload
+type VideoOptions struct {
+	// Normalize remuxes or transcodes anything that isn't an H.264/AAC MP4 and moves the index to the front
+	Normalize bool
+	// MaxSize is the size in bytes the video is compressed to fit into. Zero disables compression.
+	MaxSize int64
+	// MaxDimension caps the longest side when a video has to be compressed. Zero means 1280.
+	MaxDimension int
+}
+
+// DefaultVideoOptions normalizes videos and keeps them under DefaultMaxVideoSize
+var DefaultVideoOptions = VideoOptions{Normalize: true, MaxSize: DefaultMaxVideoSize}
+
+// PreparedVideo is a video ready for upload along with a list of what was changed to get there
+type PreparedVideo struct {
+	Video
+	MimeType string
+	Changes  []string
+}
+
+// PrepareVideo probes a video, converts it to an MP4 WhatsApp plays if needed and compresses it to fit opts.MaxSize
+func PrepareVideo(ctx context.Context, media *Media, opts VideoOptions) (*PreparedVideo, error) {
+	tempDir, err := os.MkdirTemp("", "video")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temp directory: %w", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	inputPath := filepath.Join(tempDir, "input")
+	if err := os.WriteFile(inputPath, media.Data, 0o600); err != nil {
+		return nil, fmt.Errorf("failed to write temp file: %w", err)
+	}
+
+	info, err := ProbeFile(ctx, inputPath)
+	if err != nil {
+		return nil, err
+	}
+	if info.VideoCodec == "" {
+		return nil, fmt.Errorf("media has no video stream")
+	}
+
+	prepared := &PreparedVideo{Video: Video{Data: media.Data}, MimeType: media.MimeType}
+	currentPath := inputPath
+
+	codecsOK := info.VideoCodec == "h264" && (info.AudioCodec == "" || info.AudioCodec == "aac")
+	// An H.264/AAC MP4 with its index in front already plays everywhere, so it is left alone
+	if opts.Normalize && !(codecsOK && media.MimeType == "video/mp4" && isFastStartMP4(media.Data)) {
+		outputPath := filepath.Join(tempDir, "normalized.mp4")
+
+		var args ffmpeg.KwArgs
+		var change string
+		switch {
+		case codecsOK && media.MimeType == "video/mp4":
+			args = ffmpeg.KwArgs{"c": "copy"}
+			change = "moved the MP4 index to the front for streaming"
+		case codecsOK:
+			args = ffmpeg.KwArgs{"c": "copy"}
+			change = fmt.Sprintf("remuxed %s into MP4", media.MimeType)
+		default:
+			args = transcodeArgs(info, 0, 0)
+			change = fmt.Sprintf("transcoded %s to H.264/AAC", describeCodecs(info))
+		}
+		args["movflags"] = "+faststart"
+		args["f"] = "mp4"
+
+		if err := runStream(ctx, ffmpeg.Input(currentPath).Output(outputPath, args).OverWriteOutput()); err != nil {
+			return nil, fmt.Errorf("failed to normalize video: %w", err)
+		}
+		currentPath = outputPath
+		prepared.MimeType = "video/mp4"
+		prepared.Changes = append(prepared.Changes, change)
+	}
+
+	size, err := fileSize(currentPath)
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.MaxSize > 0 && size > opts.MaxSize {
+		compressedPath, err := compressVideo(ctx, currentPath, tempDir, info, opts)
+		if err != nil {
+			return nil, err
+		}
+		currentPath = compressedPath
+		prepared.MimeType = "video/mp4"
+		newSize, err := fileSize(currentPath)
+		if err != nil {
+			return nil, err
+		}
+		prepared.Changes = append(prepared.Changes, fmt.Sprintf("compressed from %d to %d bytes", size, newSize))
+	}
+
+	if currentPath != inputPath {
+		prepared.Data, err = os.ReadFile(currentPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read prepared video: %w", err)
+		}
+		if info, err = ProbeFile(ctx, currentPath); err != nil {
+			return nil, err
+		}
+	}
+
+	prepared.Width, prepared.Height = info.Width, info.Height
+	prepared.Seconds = uint32(math.Round(info.Duration))
+	return prepared, nil
+}
+
+// isFastStartMP4 reports whether the moov box, the index players need before they can start, comes before the media data
+func isFastStartMP4(data []byte) bool {
+	for len(data) >= 8 {
+		size := uint64(binary.BigEndian.Uint32(data[0:4]))
+		boxType := string(data[4:8])
+		header := uint64(8)
+		switch size {
+		case 0:
+			// The last box runs to the end of the file
+			size = uint64(len(data))
+		case 1:
+			if len(data) < 16 {
+				return false
+			}
+			size = binary.BigEndian.Uint64(data[8:16])
+			header = 16
+		}
+		switch boxType {
+		case "moov":
+			return true
+		case "mdat":
+			return false
+		}
+		if size < header || size > uint64(len(data)) {
+			return false
+		}
+		data = data[size:]
+	}
+	return false
+}
+
+// compressVideo re-encodes at a bitrate that fits the size limit. The first attempt keeps the picture size,
+// capped at MaxDimension; every retry lowers the bitrate and shrinks the longest side by a quarter.
+func compressVideo(ctx context.Context, inputPath, tempDir string, info *ProbeInfo, opts VideoOptions) (string, error) {
+	if info.Duration <= 0 {
+		return "", fmt.Errorf("can't compress a video of unknown duration")
+	}
+	maxDimension := opts.MaxDimension
+	if maxDimension <= 0 {
+		maxDimension = 1280
+	}
+	// Start from the actual picture size, so retries also shrink videos that are already small
+	if longest := max(info.Width, info.Height); longest > 0 && longest < maxDimension {
+		maxDimension = longest
+	}
+
+	const audioBitRate = 96_000
+	// Leave some room for the container overhead
+	budget := float64(opts.MaxSize) * 8 * 0.92 / info.Duration
+	outputPath := filepath.Join(tempDir, "compressed.mp4")
+
+	for attempt := 0; attempt < 3; attempt++ {
+		videoBitRate := int(budget) - audioBitRate
+		if videoBitRate < 100_000 {
+			return "", fmt.Errorf("video is too long to fit in %d bytes", opts.MaxSize)
+		}
+
+		args := transcodeArgs(info, maxDimension, videoBitRate)
+		args["movflags"] = "+faststart"
+		args["f"] = "mp4"
+		if err := runStream(ctx, ffmpeg.Input(inputPath).Output(outputPath, args).OverWriteOutput()); err != nil {
+			return "", fmt.Errorf("failed to compress video: %w", err)
+		}
+
+		size, err := fileSize(outputPath)
+		if err != nil {
+			return "", err
+		}
+		if size <= opts.MaxSize {
+			return outputPath, nil
+		}
+		budget *= 0.75
+		// Even, since H.264 needs even dimensions
+		maxDimension = maxDimension * 3 / 4 &^ 1
+	}
+	return "", fmt.Errorf("could not compress video under %d bytes", opts.MaxSize)
+}
+
+// transcodeArgs encodes H.264/AAC, optionally scaling the longest side down to maxDimension and capping the bitrate
+func transcodeArgs(info *ProbeInfo, maxDimension int, videoBitRate int) ffmpeg.KwArgs {
+	args := ffmpeg.KwArgs{
+		"c:v":     "libx264",
+		"preset":  "veryfast",
+		"pix_fmt": "yuv420p",
+		"c:a":     "aac",
+		"b:a":     "96k",
+	}
+
+	filter := "scale=trunc(iw/2)*2:trunc(ih/2)*2"
+	if maxDimension > 0 && (info.Width > maxDimension || info.Height > maxDimension) {
+		filter = fmt.Sprintf("scale='if(gt(iw,ih),%d,-2)':'if(gt(iw,ih),-2,%d)'", maxDimension, maxDimension)
+	}
+	args["vf"] = filter
+
+	if videoBitRate > 0 {
+		args["b:v"] = strconv.Itoa(videoBitRate)
+		args["maxrate"] = strconv.Itoa(videoBitRate)
+		args["bufsize"] = strconv.Itoa(videoBitRate * 2)
+	} else {
+		args["crf"] = 23
+	}
+	if info.AudioCodec == "" {
+		delete(args, "c:a")
+		delete(args, "b:a")
+		args["an"] = ""
+	}
+	return args
+}
+
+func describeCodecs(info *ProbeInfo) string {
+	if info.AudioCodec == "" {
+		return info.VideoCodec
+	}
+	return info.VideoCodec + "/" + info.AudioCodec
+}
+
+func fileSize(path string) (int64, error) {
+	stat, err := os.Stat(path)
+	if err != nil {
+		return 0, fmt.Errorf("failed to stat video: %w", err)
+	}
+	return stat.Size(), nil
+}
@@ -0,0 +1,61 @@
+package utils
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+func mp4Box(boxType string, payload int) []byte {
+	box := make([]byte, 8+payload)
+	binary.BigEndian.PutUint32(box[0:4], uint32(len(box)))
+	copy(box[4:8], boxType)
+	return box
+}
+
+func concat(parts ...[]byte) []byte {
+	var out []byte
+	for _, part := range parts {
+		out = append(out, part...)
+	}
+	return out
+}
+
+func TestIsFastStartMP4(t *testing.T) {
+	largeMdat := make([]byte, 16+4)
+	binary.BigEndian.PutUint32(largeMdat[0:4], 1)
+	copy(largeMdat[4:8], "mdat")
+	binary.BigEndian.PutUint64(largeMdat[8:16], uint64(len(largeMdat)))
+
+	largeFree := make([]byte, 16+4)
+	binary.BigEndian.PutUint32(largeFree[0:4], 1)
+	copy(largeFree[4:8], "free")
+	binary.BigEndian.PutUint64(largeFree[8:16], uint64(len(largeFree)))
+
+	openEnded := mp4Box("mdat", 4)
+	binary.BigEndian.PutUint32(openEnded[0:4], 0)
+
+	tests := []struct {
+		name string
+		data []byte
+		want bool
+	}{
+		{name: "moov first", data: concat(mp4Box("ftyp", 16), mp4Box("moov", 32), mp4Box("mdat", 64)), want: true},
+		{name: "mdat first", data: concat(mp4Box("ftyp", 16), mp4Box("mdat", 64), mp4Box("moov", 32)), want: false},
+		{name: "free box before moov", data: concat(mp4Box("ftyp", 16), mp4Box("free", 0), mp4Box("moov", 32), mp4Box("mdat", 8)), want: true},
+		{name: "64-bit size before moov", data: concat(mp4Box("ftyp", 16), largeFree, mp4Box("moov", 32)), want: true},
+		{name: "64-bit mdat first", data: concat(mp4Box("ftyp", 16), largeMdat, mp4Box("moov", 32)), want: false},
+		{name: "mdat to end of file", data: concat(mp4Box("ftyp", 16), openEnded), want: false},
+		{name: "no moov", data: concat(mp4Box("ftyp", 16)), want: false},
+		{name: "box larger than file", data: concat([]byte{0, 0, 1, 0}, []byte("ftyp"), make([]byte, 8)), want: false},
+		{name: "box smaller than header", data: concat([]byte{0, 0, 0, 4}, []byte("ftyp"), mp4Box("moov", 0)), want: false},
+		{name: "empty", data: nil, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isFastStartMP4(tt.data); got != tt.want {
+				t.Errorf("isFastStartMP4 = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
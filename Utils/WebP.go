@@ -0,0 +1,168 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+)
+
+// WebP flags stored in the VP8X chunk
+const (
+	webpFlagAnimation = 0x02
+	webpFlagEXIF      = 0x08
+	webpFlagAlpha     = 0x10
+)
+
+const (
+	// webpChunkHeaderSize is the FourCC and length in front of every chunk
+	webpChunkHeaderSize = 8
+	webpVP8XSize        = 10
+)
+
+type webpChunk struct {
+	fourCC  string
+	payload []byte
+}
+
+// WebPInfo describes the canvas of a WebP file
+type WebPInfo struct {
+	Width    int
+	Height   int
+	Animated bool
+}
+
+// ParseWebP reads the canvas size and animation flag from a WebP file
+func ParseWebP(data []byte) (*WebPInfo, error) {
+	chunks, err := readWebPChunks(data)
+	if err != nil {
+		return nil, err
+	}
+
+	first := chunks[0]
+	switch first.fourCC {
+	case "VP8X":
+		if len(first.payload) < 10 {
+			return nil, fmt.Errorf("truncated VP8X chunk")
+		}
+		return &WebPInfo{
+			Width:    int(uint24(first.payload[4:7])) + 1,
+			Height:   int(uint24(first.payload[7:10])) + 1,
+			Animated: first.payload[0]&webpFlagAnimation != 0,
+		}, nil
+	case "VP8 ":
+		// 3 byte frame tag and 3 byte start code come before the dimensions
+		if len(first.payload) < 10 {
+			return nil, fmt.Errorf("truncated VP8 chunk")
+		}
+		return &WebPInfo{
+			Width:  int(binary.LittleEndian.Uint16(first.payload[6:8]) & 0x3fff),
+			Height: int(binary.LittleEndian.Uint16(first.payload[8:10]) & 0x3fff),
+		}, nil
+	case "VP8L":
+		if len(first.payload) < 5 || first.payload[0] != 0x2f {
+			return nil, fmt.Errorf("invalid VP8L chunk")
+		}
+		bits := binary.LittleEndian.Uint32(first.payload[1:5])
+		return &WebPInfo{
+			Width:  int(bits&0x3fff) + 1,
+			Height: int((bits>>14)&0x3fff) + 1,
+		}, nil
+	default:
+		return nil, fmt.Errorf("unexpected first WebP chunk %q", first.fourCC)
+	}
+}
+
+// SetWebPEXIF embeds exif into a WebP file, converting simple files to the extended format when needed
+func SetWebPEXIF(data []byte, exif []byte) ([]byte, error) {
+	info, err := ParseWebP(data)
+	if err != nil {
+		return nil, err
+	}
+	chunks, err := readWebPChunks(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var out []webpChunk
+	if chunks[0].fourCC == "VP8X" {
+		header := append([]byte(nil), chunks[0].payload...)
+		header[0] |= webpFlagEXIF
+		out = append(out, webpChunk{fourCC: "VP8X", payload: header})
+		chunks = chunks[1:]
+	} else {
+		header := make([]byte, webpVP8XSize)
+		header[0] = webpFlagEXIF
+		if chunks[0].fourCC == "VP8L" {
+			// Lossless images may carry alpha, and the flag is harmless when they don't
+			header[0] |= webpFlagAlpha
+		}
+		putUint24(header[4:7], uint32(info.Width-1))
+		putUint24(header[7:10], uint32(info.Height-1))
+		out = append(out, webpChunk{fourCC: "VP8X", payload: header})
+	}
+
+	// Drop any existing EXIF so the new metadata wins, and put ours at the end as the spec asks
+	for _, chunk := range chunks {
+		if chunk.fourCC != "EXIF" {
+			out = append(out, chunk)
+		}
+	}
+	out = append(out, webpChunk{fourCC: "EXIF", payload: exif})
+
+	return writeWebPChunks(out), nil
+}
+
+func readWebPChunks(data []byte) ([]webpChunk, error) {
+	if len(data) < 12 || string(data[0:4]) != "RIFF" || string(data[8:12]) != "WEBP" {
+		return nil, fmt.Errorf("not a WebP file")
+	}
+
+	var chunks []webpChunk
+	rest := data[12:]
+	for len(rest) >= 8 {
+		size := int(binary.LittleEndian.Uint32(rest[4:8]))
+		if size > len(rest)-8 {
+			return nil, fmt.Errorf("truncated WebP chunk %q", string(rest[0:4]))
+		}
+		chunks = append(chunks, webpChunk{fourCC: string(rest[0:4]), payload: rest[8 : 8+size]})
+		// Chunks are padded to an even size
+		next := 8 + size + size%2
+		if next > len(rest) {
+			break
+		}
+		rest = rest[next:]
+	}
+	if len(chunks) == 0 {
+		return nil, fmt.Errorf("WebP file has no chunks")
+	}
+	return chunks, nil
+}
+
+func writeWebPChunks(chunks []webpChunk) []byte {
+	body := new(bytes.Buffer)
+	body.WriteString("WEBP")
+	for _, chunk := range chunks {
+		body.WriteString(chunk.fourCC)
+		_ = binary.Write(body, binary.LittleEndian, uint32(len(chunk.payload)))
+		body.Write(chunk.payload)
+		if len(chunk.payload)%2 == 1 {
+			body.WriteByte(0)
+		}
+	}
+
+	out := new(bytes.Buffer)
+	out.WriteString("RIFF")
+	_ = binary.Write(out, binary.LittleEndian, uint32(body.Len()))
+	out.Write(body.Bytes())
+	return out.Bytes()
+}
+
+func uint24(b []byte) uint32 {
+	return uint32(b[0]) | uint32(b[1])<<8 | uint32(b[2])<<16
+}
+
+func putUint24(b []byte, v uint32) {
+	b[0] = byte(v)
+	b[1] = byte(v >> 8)
+	b[2] = byte(v >> 16)
+}
@@ -0,0 +1,217 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"testing"
+)
+
+// buildWebP assembles a WebP file from chunks with a correct RIFF size
+func buildWebP(chunks ...webpChunk) []byte {
+	return writeWebPChunks(chunks)
+}
+
+func vp8lChunk(width, height int) webpChunk {
+	bits := uint32(width-1) | uint32(height-1)<<14
+	payload := []byte{0x2f, 0, 0, 0, 0, 0x01, 0x02}
+	binary.LittleEndian.PutUint32(payload[1:5], bits)
+	return webpChunk{fourCC: "VP8L", payload: payload}
+}
+
+func vp8Chunk(width, height int) webpChunk {
+	payload := []byte{0x10, 0x02, 0x00, 0x9d, 0x01, 0x2a, 0, 0, 0, 0, 0xaa, 0xbb}
+	binary.LittleEndian.PutUint16(payload[6:8], uint16(width))
+	binary.LittleEndian.PutUint16(payload[8:10], uint16(height))
+	return webpChunk{fourCC: "VP8 ", payload: payload}
+}
+
+func vp8xChunk(width, height int, flags byte) webpChunk {
+	payload := make([]byte, webpVP8XSize)
+	payload[0] = flags
+	putUint24(payload[4:7], uint32(width-1))
+	putUint24(payload[7:10], uint32(height-1))
+	return webpChunk{fourCC: "VP8X", payload: payload}
+}
+
+func TestParseWebP(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		want WebPInfo
+		err  bool
+	}{
+		{name: "lossless", data: buildWebP(vp8lChunk(512, 300)), want: WebPInfo{Width: 512, Height: 300}},
+		{name: "lossy", data: buildWebP(vp8Chunk(320, 240)), want: WebPInfo{Width: 320, Height: 240}},
+		{name: "extended", data: buildWebP(vp8xChunk(512, 512, webpFlagAlpha), vp8lChunk(512, 512)), want: WebPInfo{Width: 512, Height: 512}},
+		{name: "animated", data: buildWebP(vp8xChunk(100, 80, webpFlagAnimation), webpChunk{fourCC: "ANIM", payload: make([]byte, 6)}), want: WebPInfo{Width: 100, Height: 80, Animated: true}},
+		{name: "not RIFF", data: []byte("GIF89a......"), err: true},
+		{name: "too short", data: []byte("RIFF"), err: true},
+		{name: "no chunks", data: []byte("RIFF\x04\x00\x00\x00WEBP"), err: true},
+		{name: "truncated chunk", data: []byte("RIFF\x10\x00\x00\x00WEBPVP8L\x20\x00\x00\x00\x2f"), err: true},
+		{name: "unknown first chunk", data: buildWebP(webpChunk{fourCC: "ALPH", payload: []byte{1, 2}}), err: true},
+		{name: "invalid lossless signature", data: buildWebP(webpChunk{fourCC: "VP8L", payload: []byte{0, 0, 0, 0, 0}}), err: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info, err := ParseWebP(tt.data)
+			if tt.err {
+				if err == nil {
+					t.Fatalf("expected an error, got %+v", info)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if *info != tt.want {
+				t.Errorf("got %+v, want %+v", *info, tt.want)
+			}
+		})
+	}
+}
+
+func TestSetWebPEXIF(t *testing.T) {
+	tests := []struct {
+		name       string
+		data       []byte
+		exif       []byte
+		wantChunks []string
+		wantFlags  byte
+	}{
+		{
+			name:       "lossless gets an extended header",
+			data:       buildWebP(vp8lChunk(512, 512)),
+			exif:       []byte("even"),
+			wantChunks: []string{"VP8X", "VP8L", "EXIF"},
+			wantFlags:  webpFlagEXIF | webpFlagAlpha,
+		},
+		{
+			name:       "lossy gets an extended header without alpha",
+			data:       buildWebP(vp8Chunk(512, 512)),
+			exif:       []byte("odd"),
+			wantChunks: []string{"VP8X", "VP8 ", "EXIF"},
+			wantFlags:  webpFlagEXIF,
+		},
+		{
+			name: "extended keeps its flags and chunks",
+			data: buildWebP(vp8xChunk(512, 512, webpFlagAnimation|webpFlagAlpha),
+				webpChunk{fourCC: "ANIM", payload: make([]byte, 6)},
+				webpChunk{fourCC: "ANMF", payload: make([]byte, 17)}),
+			exif:       []byte("metadata"),
+			wantChunks: []string{"VP8X", "ANIM", "ANMF", "EXIF"},
+			wantFlags:  webpFlagEXIF | webpFlagAnimation | webpFlagAlpha,
+		},
+		{
+			name: "existing EXIF is replaced",
+			data: buildWebP(vp8xChunk(512, 512, webpFlagEXIF), vp8lChunk(512, 512),
+				webpChunk{fourCC: "EXIF", payload: []byte("old metadata")}),
+			exif:       []byte("new"),
+			wantChunks: []string{"VP8X", "VP8L", "EXIF"},
+			wantFlags:  webpFlagEXIF,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before, err := ParseWebP(tt.data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			out, err := SetWebPEXIF(tt.data, tt.exif)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// The RIFF size covers everything after the size field, and chunks stay even-aligned
+			if size := binary.LittleEndian.Uint32(out[4:8]); int(size) != len(out)-8 {
+				t.Errorf("RIFF size = %d, want %d", size, len(out)-8)
+			}
+			if len(out)%2 != 0 {
+				t.Errorf("file length %d is odd", len(out))
+			}
+
+			chunks, err := readWebPChunks(out)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var names []string
+			for _, chunk := range chunks {
+				names = append(names, chunk.fourCC)
+			}
+			if len(names) != len(tt.wantChunks) {
+				t.Fatalf("chunks = %q, want %q", names, tt.wantChunks)
+			}
+			for i := range names {
+				if names[i] != tt.wantChunks[i] {
+					t.Fatalf("chunks = %q, want %q", names, tt.wantChunks)
+				}
+			}
+			if flags := chunks[0].payload[0]; flags != tt.wantFlags {
+				t.Errorf("VP8X flags = %#x, want %#x", flags, tt.wantFlags)
+			}
+			if exif := chunks[len(chunks)-1].payload; !bytes.Equal(exif, tt.exif) {
+				t.Errorf("EXIF = %q, want %q", exif, tt.exif)
+			}
+
+			after, err := ParseWebP(out)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if *after != *before {
+				t.Errorf("canvas changed from %+v to %+v", *before, *after)
+			}
+		})
+	}
+}
+
+func TestSetWebPEXIFInvalid(t *testing.T) {
+	if _, err := SetWebPEXIF([]byte("not a webp file"), []byte("exif")); err == nil {
+		t.Error("expected an error for data that isn't WebP")
+	}
+}
+
+func TestConvertToStickerMetadataSize(t *testing.T) {
+	metadata := StickerMetadata{PackName: "Pack", Publisher: "Tester", Emojis: []string{"🙂"}}
+	animated := func(size int) []byte {
+		// Pad an animated sticker with an unknown chunk until the file has the given size
+		header := buildWebP(vp8xChunk(StickerSize, StickerSize, webpFlagAnimation), webpChunk{fourCC: "ANIM", payload: make([]byte, 6)})
+		padding := size - len(header) - webpChunkHeaderSize
+		return buildWebP(vp8xChunk(StickerSize, StickerSize, webpFlagAnimation),
+			webpChunk{fourCC: "ANIM", payload: make([]byte, 6)},
+			webpChunk{fourCC: "XPAD", payload: make([]byte, padding)})
+	}
+
+	tests := []struct {
+		name     string
+		data     []byte
+		metadata StickerMetadata
+		err      bool
+	}{
+		{name: "fits without metadata", data: animated(MaxAnimatedStickerSize), err: false},
+		{name: "metadata pushes it over the limit", data: animated(MaxAnimatedStickerSize), metadata: metadata, err: true},
+		{name: "room for metadata", data: animated(MaxAnimatedStickerSize - 1024), metadata: metadata, err: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sticker, err := ConvertToSticker(context.Background(), &Media{Data: tt.data, MimeType: "image/webp"}, tt.metadata)
+			if tt.err {
+				if err == nil {
+					t.Fatalf("expected an error, got a %d byte sticker", len(sticker.Data))
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(sticker.Data) > MaxAnimatedStickerSize {
+				t.Errorf("sticker is %d bytes, over the %d byte limit", len(sticker.Data), MaxAnimatedStickerSize)
+			}
+			if !sticker.Animated {
+				t.Error("animated sticker came back static")
+			}
+		})
+	}
+}
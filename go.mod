@@ -2,11 +2,23 @@ module github.com/hacxk/easy-meow
 
 go 1.22.5
 
+require (
+	github.com/disintegration/imaging v1.6.2
+	github.com/mattn/go-sqlite3 v1.14.22
+	github.com/mdp/qrterminal/v3 v3.2.0
+	github.com/nfnt/resize v0.0.0-20180221191011-83c6a9932646
+	github.com/rs/zerolog v1.33.0
+	github.com/u2takey/ffmpeg-go v0.5.0
+	go.mau.fi/whatsmeow v0.0.0-20240710112833-d732338c041f
+	golang.org/x/net v0.27.0
+	google.golang.org/protobuf v1.34.2
+	rsc.io/qr v0.2.0
+)
+
 require (
 	filippo.io/edwards25519 v1.1.0 // indirect
 	github.com/3d0c/gmf v0.0.0-20220906170454-be727bc5b56c // indirect
 	github.com/aws/aws-sdk-go v1.54.19 // indirect
-	github.com/disintegration/imaging v1.6.2 // indirect
 	github.com/google/uuid v1.6.0 // indirect
 	github.com/gorilla/websocket v1.5.0 // indirect
 	github.com/h2non/bimg v1.1.9 // indirect
@@ -14,20 +26,11 @@ require (
 	github.com/jmespath/go-jmespath v0.4.0 // indirect
 	github.com/mattn/go-colorable v0.1.13 // indirect
 	github.com/mattn/go-isatty v0.0.20 // indirect
-	github.com/mattn/go-sqlite3 v1.14.22 // indirect
-	github.com/mdp/qrterminal/v3 v3.2.0 // indirect
-	github.com/nfnt/resize v0.0.0-20180221191011-83c6a9932646 // indirect
-	github.com/rs/zerolog v1.33.0 // indirect
-	github.com/u2takey/ffmpeg-go v0.5.0 // indirect
 	github.com/u2takey/go-utils v0.3.1 // indirect
 	go.mau.fi/libsignal v0.1.1-0.20240705162345-47e713a595ab // indirect
 	go.mau.fi/util v0.5.0 // indirect
-	go.mau.fi/whatsmeow v0.0.0-20240710112833-d732338c041f // indirect
 	golang.org/x/crypto v0.25.0 // indirect
 	golang.org/x/image v0.0.0-20191009234506-e7c1f5e7dbb8 // indirect
-	golang.org/x/net v0.27.0 // indirect
 	golang.org/x/sys v0.22.0 // indirect
 	golang.org/x/term v0.22.0 // indirect
-	google.golang.org/protobuf v1.34.2 // indirect
-	rsc.io/qr v0.2.0 // indirect
 )